@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"iter"
+	"maps"
 	"os"
 	"reflect"
 	"regexp"
@@ -26,12 +27,25 @@ type CommandImmutable interface {
 	Prologue() iter.Seq[string]
 	Epilogue() iter.Seq[string]
 	Subcommands() iter.Seq[CommandImmutable]
+	EnvPrefix() string
+	ParseMode() ParseMode
+	Completer(field string) Completer
+	Persistent() iter.Seq[reflect.Type]
+	Middleware() iter.Seq[Middleware]
+	IsHidden() bool
+	Deprecated() string
 	PrintHelp()
 	String() string
 	Output() io.Writer
 	Helper() Helper
 	Decoder() Decoder
 	Validator() Validator
+	Getter() Getter
+	Prompter() Prompter
+	ResponseFiles() bool
+	Plugins() bool
+	FlagActions() iter.Seq2[string, string]
+	FlagAction(flag string) (func(command CommandImmutable, args []string) error, bool)
 }
 
 func (c Command[T]) Type() reflect.Type {
@@ -95,16 +109,60 @@ func (c Command[T]) Epilogue() iter.Seq[string] {
 	return slices.Values(c.epilogue)
 }
 
+// Subcommands with their parent set to this command.
 func (c Command[T]) Subcommands() iter.Seq[CommandImmutable] {
 	return func(yield func(CommandImmutable) bool) {
 		for _, subcommand := range c.subcommands {
-			if !yield(subcommand) {
+			if !yield(subcommand.WithParent(c)) {
 				return
 			}
 		}
 	}
 }
 
+// Get the environment variable name prefix, defaulting to the parent command's
+// prefix if no prefix is explicitly set.
+func (c Command[T]) EnvPrefix() string {
+	if c.envPrefix == "" && c.parent != nil {
+		return c.parent.EnvPrefix()
+	}
+
+	return c.envPrefix
+}
+
+func (c Command[T]) ParseMode() ParseMode {
+	return c.parseMode
+}
+
+// Get the completer for a struct field by name, or nil if the field has no
+// completer.
+func (c Command[T]) Completer(field string) Completer {
+	return c.completers[field]
+}
+
+// Persistent option struct types declared by this command (not including
+// those declared by parent commands).
+func (c Command[T]) Persistent() iter.Seq[reflect.Type] {
+	return slices.Values(c.persistent)
+}
+
+// Action middleware added to this command (not including middleware added to
+// parent commands).
+func (c Command[T]) Middleware() iter.Seq[Middleware] {
+	return slices.Values(c.middleware)
+}
+
+// True if the command is omitted from help text and completions.
+func (c Command[T]) IsHidden() bool {
+	return c.hidden
+}
+
+// Get the deprecation message, or an empty string if the command is not
+// deprecated.
+func (c Command[T]) Deprecated() string {
+	return c.deprecated
+}
+
 // Get command help text.
 func (c Command[T]) String() string {
 	helper := shorthand.Coalesce(c.helper, HelperDefault)
@@ -139,3 +197,69 @@ func (c Command[T]) Decoder() Decoder {
 func (c Command[T]) Validator() Validator {
 	return shorthand.Coalesce(c.validator, PlaygroundValidator)
 }
+
+// Get the Prompter, defaulting to the parent command's prompter. Returns nil
+// if prompting is not enabled.
+func (c Command[T]) Prompter() Prompter {
+	if c.prompter == nil && c.parent != nil {
+		return c.parent.Prompter()
+	}
+
+	return c.prompter
+}
+
+// True if response file arguments are expanded, either by this command or a
+// parent command.
+func (c Command[T]) ResponseFiles() bool {
+	return c.responseFiles || (c.parent != nil && c.parent.ResponseFiles())
+}
+
+// Get the flag action flags (sorted) and their help texts, including the flag
+// actions inherited from parent commands.
+func (c Command[T]) FlagActions() iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		helps := map[string]string{}
+
+		if c.parent != nil {
+			maps.Insert(helps, c.parent.FlagActions())
+		}
+
+		for flag, flagAction := range c.flagActions {
+			helps[flag] = flagAction.help
+		}
+
+		for _, flag := range slices.Sorted(maps.Keys(helps)) {
+			if !yield(flag, helps[flag]) {
+				return
+			}
+		}
+	}
+}
+
+// Get the action of a flag, set by this command or a parent command.
+func (c Command[T]) FlagAction(flag string) (func(command CommandImmutable, args []string) error, bool) {
+	if flagAction, ok := c.flagActions[flag]; ok {
+		return flagAction.action, true
+	}
+
+	if c.parent != nil {
+		return c.parent.FlagAction(flag)
+	}
+
+	return nil, false
+}
+
+// True if external plugin commands are run when no subcommand matches.
+func (c Command[T]) Plugins() bool {
+	return c.plugins
+}
+
+// Get the non-nil environment variable Getter, defaulting to the parent
+// command's getter, or [GetterDefault] if no getter is explicitly set.
+func (c Command[T]) Getter() Getter {
+	if c.getter == nil && c.parent != nil {
+		return c.parent.Getter()
+	}
+
+	return shorthand.Coalesce(c.getter, GetterDefault)
+}
@@ -1,6 +1,9 @@
 package command
 
-import "io"
+import (
+	"io"
+	"reflect"
+)
 
 type CommandMutable interface {
 	CommandImmutable
@@ -20,10 +23,25 @@ type CommandMutable interface {
 	SetSubcommands(subcommands ...Subcommand)
 	AddSubcommand(subcommand Subcommand)
 
+	SetEnvPrefix(prefix string)
+	SetParseMode(mode ParseMode)
+	SetCompleter(field string, completer Completer)
+	AddPersistent(structType reflect.Type)
+	SetHidden(hidden bool)
+	SetDeprecated(message string)
+	SetFlagAction(flag string, help string, action func(command CommandImmutable, args []string) error)
+	AddMiddleware(middleware Middleware)
+
+	SetExit(exit func(code int))
 	SetOutput(output io.Writer)
 	SetHelper(helper Helper)
 	SetDecoder(decoder Decoder)
 	SetValidator(validator Validator)
+	SetGetter(getter Getter)
+	SetPrompter(prompter Prompter)
+	SetResponseFiles(enabled bool)
+	SetPlugins(enabled bool)
+	SetInitial(initial func() any)
 }
 
 func (c *Command[T]) SetName(name string) {
@@ -66,6 +84,56 @@ func (c *Command[T]) AddSubcommand(subcommand Subcommand) {
 	c.subcommands = append(c.subcommands, subcommand)
 }
 
+func (c *Command[T]) SetEnvPrefix(prefix string) {
+	c.envPrefix = prefix
+}
+
+func (c *Command[T]) SetParseMode(mode ParseMode) {
+	c.parseMode = mode
+}
+
+func (c *Command[T]) SetCompleter(field string, completer Completer) {
+	if c.completers == nil {
+		c.completers = map[string]Completer{}
+	}
+
+	c.completers[field] = completer
+}
+
+func (c *Command[T]) AddPersistent(structType reflect.Type) {
+	c.persistent = append(c.persistent, structType)
+}
+
+func (c *Command[T]) SetHidden(hidden bool) {
+	c.hidden = hidden
+}
+
+func (c *Command[T]) SetDeprecated(message string) {
+	c.deprecated = message
+}
+
+// Set an action which runs instead of the command when the flag is found
+// among the named arguments of the command or any of its subcommands. The
+// action receives the running command and the arguments following the flag.
+// Flag actions with help text are included in help text and completions.
+func (c *Command[T]) SetFlagAction(flag string, help string, action func(command CommandImmutable, args []string) error) {
+	if c.flagActions == nil {
+		c.flagActions = map[string]flagAction{}
+	}
+
+	c.flagActions[flag] = flagAction{help: help, action: action}
+}
+
+func (c *Command[T]) AddMiddleware(middleware Middleware) {
+	c.middleware = append(c.middleware, middleware)
+}
+
+// Set the function called by the "AndExit" run methods. Defaults to [os.Exit]
+// if nil.
+func (c *Command[T]) SetExit(exit func(code int)) {
+	c.exit = exit
+}
+
 func (c *Command[T]) SetOutput(output io.Writer) {
 	c.output = output
 }
@@ -81,3 +149,25 @@ func (c *Command[T]) SetDecoder(decoder Decoder) {
 func (c *Command[T]) SetValidator(validator Validator) {
 	c.validator = validator
 }
+
+func (c *Command[T]) SetGetter(getter Getter) {
+	c.getter = getter
+}
+
+func (c *Command[T]) SetPrompter(prompter Prompter) {
+	c.prompter = prompter
+}
+
+func (c *Command[T]) SetResponseFiles(enabled bool) {
+	c.responseFiles = enabled
+}
+
+func (c *Command[T]) SetPlugins(enabled bool) {
+	c.plugins = enabled
+}
+
+// Set the factory for the initial value of the option struct, which must
+// return a pointer to the command's option struct type.
+func (c *Command[T]) SetInitial(initial func() any) {
+	c.initial = initial
+}
@@ -1,12 +1,16 @@
 package command
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"reflect"
+	"slices"
+	"strings"
 
 	"seahax.com/go/shorthand"
 )
@@ -14,35 +18,83 @@ import (
 // Command definition including the struct type, action to run, subcommands,
 // help texts, etc.
 type Command[T any] struct {
-	action func(opts *T) error
+	action func(ctx context.Context, opts *T) error
+	notify bool
 	exit   func(code int)
 	parent CommandImmutable
 
-	name        string
-	summary     string
-	usage       []string
-	prologue    []string
-	epilogue    []string
-	subcommands []Subcommand
+	name          string
+	summary       string
+	usage         []string
+	prologue      []string
+	epilogue      []string
+	subcommands   []Subcommand
+	envPrefix     string
+	parseMode     ParseMode
+	completers    map[string]Completer
+	persistent    []reflect.Type
+	hidden        bool
+	deprecated    string
+	flagActions   map[string]flagAction
+	middleware    []Middleware
+	prompter      Prompter
+	responseFiles bool
+	plugins       bool
+	initial       func() any
+	// Middleware is not applied to the action (built-in subcommands and
+	// namespaces).
+	noMiddleware bool
 
 	output    io.Writer
 	helper    Helper
 	decoder   Decoder
 	validator Validator
+	getter    Getter
+}
+
+// Flag action set by [CommandMutable.SetFlagAction].
+type flagAction struct {
+	help   string
+	action func(command CommandImmutable, args []string) error
 }
 
 // Subcommand interface. All commands are also inherently subcommands.
 type Subcommand interface {
 	CommandImmutable
+	WithParent(parent CommandImmutable) Subcommand
 	RunAsSubcommand(parent CommandImmutable, args []string) *Error
 }
 
+// Subcommand which can also be run with a context. The parent command's
+// context (eg. persistent option values) is passed to subcommands which
+// implement this interface. Other subcommands are run with
+// [Subcommand.RunAsSubcommand].
+type SubcommandContext interface {
+	Subcommand
+	RunAsSubcommandContext(ctx context.Context, parent CommandImmutable, args []string) *Error
+}
+
 // Create a new [Command].
 func New[T any](name string, summary string, action func(opts *T) error, modifiers ...Modifier) Command[T] {
+	return newCommand(name, summary, func(_ context.Context, opts *T) error {
+		return action(opts)
+	}, false, modifiers)
+}
+
+// Create a new [Command] with an action that accepts a context. The context
+// is cancelled when an interrupt (SIGINT) or termination (SIGTERM) signal is
+// received while the action is running. A second signal exits the process
+// immediately.
+func NewContext[T any](name string, summary string, action func(ctx context.Context, opts *T) error, modifiers ...Modifier) Command[T] {
+	return newCommand(name, summary, action, true, modifiers)
+}
+
+func newCommand[T any](name string, summary string, action func(ctx context.Context, opts *T) error, notify bool, modifiers []Modifier) Command[T] {
 	cmd := Command[T]{
 		name:    name,
 		summary: summary,
 		action:  action,
+		notify:  notify,
 	}
 
 	for _, modifier := range modifiers {
@@ -52,6 +104,15 @@ func New[T any](name string, summary string, action func(opts *T) error, modifie
 	return cmd
 }
 
+// Create a new built-in [Command] (eg. the "completion" subcommand), which
+// is not wrapped by [Middleware], because its options type is not the one that
+// middleware expects.
+func newBuiltin[T any](name string, summary string, action func(ctx context.Context, opts *T) error, modifiers ...Modifier) Command[T] {
+	cmd := newCommand(name, summary, action, false, modifiers)
+	cmd.noMiddleware = true
+	return cmd
+}
+
 // Run the command with the process arguments.
 func (c Command[T]) Run() *Error {
 	return c.RunArgs(os.Args[1:])
@@ -59,32 +120,149 @@ func (c Command[T]) Run() *Error {
 
 // Run the command with the provided arguments.
 func (c Command[T]) RunArgs(args []string) *Error {
-	if len(args) > 0 {
-		for _, subcommand := range c.subcommands {
-			for name := range subcommand.Names() {
-				if name == args[0] {
-					return subcommand.RunAsSubcommand(c, args[1:])
+	return c.RunArgsContext(context.Background(), args)
+}
+
+// Run the command with the process arguments and a parent context.
+func (c Command[T]) RunContext(ctx context.Context) *Error {
+	return c.RunArgsContext(ctx, os.Args[1:])
+}
+
+// Run the command with the provided arguments and a parent context.
+func (c Command[T]) RunArgsContext(ctx context.Context, args []string) *Error {
+	if c.deprecated != "" {
+		fmt.Fprintf(c.Output(), "warning: command %q is deprecated: %s\n", c.Fullname(), c.deprecated)
+	}
+
+	if c.ResponseFiles() && (c.parent == nil || !c.parent.ResponseFiles()) {
+		expanded, err := expandResponseFiles(args, 0)
+
+		if err != nil {
+			return &Error{error: err, command: c, IsParseFailure: true}
+		}
+
+		args = expanded
+	}
+
+	persistent, persistentSeen, err := persistentValues(ctx, c)
+
+	if err != nil {
+		return &Error{error: err, command: c, IsParseFailure: true}
+	}
+
+	if len(args) > 0 && (len(c.subcommands) > 0 || c.plugins) {
+		// Leading persistent flags may precede the subcommand name. They are
+		// parsed into copies, in case the arguments do not select a subcommand.
+		persistentCopy := persistentClone(persistent)
+		parser := c.newParser(nil)
+		parser.Persistent = persistentCopy
+		parser.persistentSeen = persistentSeen
+
+		if rest, seen, err := parser.parseLeading(args); err == nil && len(rest) > 0 {
+			for _, subcommand := range c.subcommands {
+				for name := range subcommand.Names() {
+					if name == rest[0] {
+						ctx = persistentContextKey.ApplyValue(ctx, persistentCopy)
+						ctx = persistentSeenContextKey.ApplyValue(ctx, seen)
+						if subcommand, ok := subcommand.(SubcommandContext); ok {
+							return subcommand.RunAsSubcommandContext(ctx, c, rest[1:])
+						}
+
+						return subcommand.RunAsSubcommand(c, rest[1:])
+					}
+				}
+			}
+
+			if c.plugins {
+				if path, ok := pluginLookup(c, rest[0]); ok {
+					if err := pluginRun(ctx, path, rest[1:]); err != nil {
+						return &Error{error: err, command: c, IsParseFailure: false}
+					}
+
+					return nil
 				}
 			}
 		}
 	}
 
 	structType := reflect.TypeFor[T]()
-	decoder := shorthand.Coalesce(c.decoder, DecoderDefault)
-	parser := NewParser(structType, decoder)
-	parsedPtr, err := parser.Parse(args)
+	parser := c.newParser(structType)
+	parser.Persistent = persistent
+	parser.persistentSeen = persistentSeen
+	parser.Prompter = c.Prompter()
+
+	if prompter, ok := parser.Prompter.(prompterOutput); ok {
+		parser.Prompter = prompter.withOutput(c.Output())
+	}
+	parser.Initial = c.initial
+	parser.actionFlags = slices.Collect(maps.Keys(maps.Collect(c.FlagActions())))
+	parsedPtr, sources, err := parser.ParseSources(args)
+
+	if actionErr := (*parserActionError)(nil); errors.As(err, &actionErr) {
+		action, _ := c.FlagAction(actionErr.flag)
+
+		if err := action(c, actionErr.args); err != nil {
+			if err, ok := err.(*Error); ok {
+				err.command = c
+				return err
+			}
+
+			return &Error{error: err, command: c}
+		}
+
+		return nil
+	}
 
 	if err != nil {
+		var unknownFlagErr *UnknownFlagError
+
+		if errors.As(err, &unknownFlagErr) {
+			return &Error{
+				error:          err,
+				command:        c,
+				IsParseFailure: true,
+				Suggestions:    suggestFlags(unknownFlagErr.Flag, append(persistentTypes(c), structType)...),
+			}
+		}
+
 		return &Error{error: err, command: c, IsParseFailure: true}
 	}
 
 	validator := shorthand.Coalesce(c.validator, PlaygroundValidator)
 
-	if err := validator.Validate(parsedPtr); err != nil {
-		return &Error{error: err, command: c, IsParseFailure: true}
+	for _, value := range append([]any{parsedPtr}, persistent...) {
+		if err := validator.Validate(value); err != nil {
+			return &Error{error: err, command: c, IsParseFailure: true}
+		}
+	}
+
+	ctx = commandContextKey.ApplyValue(ctx, c)
+	ctx = persistentContextKey.ApplyValue(ctx, persistent)
+	ctx = sourcesContextKey.ApplyValue(ctx, sources)
+
+	if c.notify {
+		var stop context.CancelFunc
+		ctx, stop = signalContext(ctx)
+		defer stop()
 	}
 
-	if err := c.action(parsedPtr.(*T)); err != nil {
+	closeFileSources, err := openFileSources(parsedPtr)
+
+	if err != nil {
+		return &Error{error: err, command: c, IsParseFailure: false}
+	}
+
+	defer closeFileSources()
+
+	action := func(ctx context.Context, _ CommandImmutable, opts any) error {
+		return c.action(ctx, opts.(*T))
+	}
+
+	if !c.noMiddleware {
+		action = hookWrap(c, action)
+	}
+
+	if err := action(ctx, c, parsedPtr); err != nil {
 		if err, ok := err.(*Error); ok {
 			err.command = c
 			return err
@@ -96,6 +274,15 @@ func (c Command[T]) RunArgs(args []string) *Error {
 	return nil
 }
 
+func (c Command[T]) newParser(structType reflect.Type) Parser {
+	parser := NewParser(structType, c.Decoder())
+	parser.Getter = c.Getter()
+	parser.EnvPrefix = c.EnvPrefix()
+	parser.Mode = c.parseMode
+	parser.Output = c.Output()
+	return parser
+}
+
 // Run the command with the process arguments and exit with an appropriate
 // status code.
 func (c Command[T]) RunAndExit() {
@@ -112,30 +299,43 @@ func (c Command[T]) RunArgsAndExit(args []string) {
 			err.command.PrintHelp()
 		}
 
-		if errors.Is(err, flag.ErrHelp) {
-			exit(0)
+		if errors.Is(err, flag.ErrHelp) || errors.As(err, new(*pluginExitError)) {
+			// Help was requested, or a plugin has already reported its error.
+			exit(err.ExitCode())
 			return
 		}
 
 		fmt.Fprintln(c.Output(), err.Error())
 
-		if err.IsParseFailure {
-			exit(1)
-			return
+		for line := range strings.Lines(err.Hint()) {
+			fmt.Fprintf(c.Output(), "hint: %s\n", strings.TrimRight(line, "\n"))
 		}
 
-		exit(2)
+		exit(err.ExitCode())
 		return
 	}
 
-	exit(0)
+	exit(ExitCodeSuccess)
+}
+
+// Return a copy of the command with the parent command set, so that it
+// inherits the parent command's output writer and name.
+func (c Command[T]) WithParent(parent CommandImmutable) Subcommand {
+	c.parent = parent
+	return c
 }
 
 // Run the command as a subcommand, inheriting the parent command's output
 // writer and name.
 func (c Command[T]) RunAsSubcommand(parent CommandImmutable, args []string) *Error {
+	return c.RunAsSubcommandContext(context.Background(), parent, args)
+}
+
+// Run the command as a subcommand with a context, inheriting the parent
+// command's output writer and name.
+func (c Command[T]) RunAsSubcommandContext(ctx context.Context, parent CommandImmutable, args []string) *Error {
 	c.parent = parent
-	return c.RunArgs(args)
+	return c.RunArgsContext(ctx, args)
 }
 
 // Add this command as a subcommand to the provided parent command.
@@ -2,6 +2,13 @@ package command
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
 	"testing"
 
 	"seahax.com/go/assert"
@@ -12,8 +19,8 @@ func TestParsing(t *testing.T) {
 	type Opts struct {
 		Opt  int      `flag:"--int"`
 		Bool bool     `flag:"-b"`
-		Arg  string   `flag:"<arg>"`
-		Args []string `flag:"<args...>"`
+		Arg  string   `flag:"[arg]"`
+		Args []string `flag:"[args...]"`
 	}
 
 	var count int
@@ -119,7 +126,7 @@ func TestHelp(t *testing.T) {
 	)
 
 	assert.Equal(t, cmd.String(), shorthand.Multiline(`
-	| Usage: test <options> <arguments>
+	| Usage: test <options> <value> <rest...>
 	| Usage: test <command> ...
 	|
 	| The root command
@@ -251,3 +258,1745 @@ func TestCommandAlias(t *testing.T) {
 	|
 	`))
 }
+
+func TestEnv(t *testing.T) {
+	type Opts struct {
+		Opt  int      `flag:"--int <value>" env:"INT" help:"An option"`
+		List []string `flag:"--list <value>" env:"LIST"`
+		Arg  string   `flag:"[arg]" env:"ARG"`
+	}
+
+	var opts *Opts
+	cmd := New("test", "",
+		func(o *Opts) error {
+			opts = o
+			return nil
+		},
+		Modify(func(command CommandMutable) {
+			command.SetEnvPrefix("APP_")
+			command.SetGetter(Get(func(name string) (string, bool) {
+				switch name {
+				case "APP_INT":
+					return "123", true
+				case "APP_LIST":
+					return "a,b", true
+				}
+
+				return "", false
+			}))
+		}),
+	)
+
+	err := cmd.RunArgs([]string{})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{
+		Opt:  123,
+		List: []string{"a", "b"},
+	})
+
+	// Arguments take precedence over the environment.
+	err = cmd.RunArgs([]string{"--int", "1", "--list", "c", "d"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{
+		Opt:  1,
+		List: []string{"c"},
+		Arg:  "d",
+	})
+
+	assert.Equal(t, cmd.String(), shorthand.Multiline(`
+	| Usage: test <options> [arg]
+	|
+	| Options:
+	|   --int <value> [env: APP_INT]
+	|       An option
+	|
+	`))
+}
+
+func TestEnvInvalid(t *testing.T) {
+	type Opts struct {
+		Opt int `flag:"--int <value>" env:"INT"`
+	}
+
+	cmd := New("test", "", func(_ *Opts) error {
+		return nil
+	})
+	cmd.getter = Get(func(name string) (string, bool) {
+		return "abc", true
+	})
+
+	err := cmd.RunArgs([]string{})
+
+	assert.NotEqual(t, err, nil)
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Error(), "failed parsing environment \"INT\": failed decoding int from \"abc\"")
+}
+
+func TestCompletion(t *testing.T) {
+	type Opts struct {
+		Region string   `flag:"-r, --region <name>" help:"Region"`
+		Force  bool     `flag:"-f, --force" help:"Force"`
+		Color  string   `flag:"--color <when>" choices:"auto|never" help:"Color"`
+		Files  []string `flag:"<files...>" help:"Files"`
+	}
+
+	cmd := Namespace("tool", "",
+		Completion(),
+		New("deploy, d", "Deploy", func(_ *Opts) error {
+			return nil
+		}, Modify(func(command CommandMutable) {
+			command.SetCompleter("Region", Complete(func(prefix string) []string {
+				return []string{prefix + "east", prefix + "west"}
+			}))
+			command.SetCompleter("Files", Complete(func(prefix string) []string {
+				return []string{"file"}
+			}))
+		})),
+	)
+
+	output := &bytes.Buffer{}
+	err := WriteCompletion(output, cmd, "bash")
+	assert.Equal(t, err, nil)
+	assert.RegexpMatch(t, output.String(), `'tool deploy' \| 'tool d'\) cmdpath='tool deploy' ;;`)
+	assert.RegexpMatch(t, output.String(), `'tool deploy -r' \| 'tool deploy --region' \| 'tool deploy --color'\) valueflag=`)
+	assert.RegexpMatch(t, output.String(), `'tool deploy --color'\) words=\('auto' 'never'\) ;;`)
+	assert.RegexpMatch(t, output.String(), `'tool deploy'\) words=\('-r' '--region' '-f' '--force' '--color' '--help'\) ;;`)
+	assert.RegexpMatch(t, output.String(), `'tool' __complete -- `)
+	assert.NotRegexpMatch(t, output.String(), `'tool __complete'`)
+
+	err = WriteCompletion(output, cmd, "powershell")
+	assert.Equal(t, err.Error(), "unsupported shell \"powershell\"")
+
+	assert.Equal(t, completeDynamic(cmd, []string{"d", "--region", "x-"}), []string{"x-east", "x-west"})
+	assert.Equal(t, completeDynamic(cmd, []string{"deploy", "-f", "a", ""}), []string{"file"})
+	assert.Equal(t, completeDynamic(cmd, []string{"deploy", "--"}), nil)
+	assert.Equal(t, completeDynamic(cmd, []string{"deploy", "--region=x", ""}), []string{"file"})
+}
+
+func TestDocs(t *testing.T) {
+	type Opts struct {
+		Opt int    `flag:"-o, --opt <value>" env:"OPT" help:"This is an option"`
+		Arg string `flag:"<arg>" help:"This is an argument"`
+	}
+
+	cmd := Namespace("tool", "The tool",
+		Modify(func(command CommandMutable) {
+			command.AddPrologue("A prologue.")
+			command.AddEpilogue("An epilogue.")
+		}),
+		New("sub, s", "A subcommand", func(_ *Opts) error {
+			return nil
+		}),
+	)
+
+	man := &bytes.Buffer{}
+	err := WriteMan(man, shorthand.FirstSeqValue(cmd.Subcommands()))
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, man.String(), shorthand.Multiline(`
+	| .TH "TOOL-SUB" "1"
+	| .SH NAME
+	| tool\-sub \- A subcommand
+	| .SH SYNOPSIS
+	| .nf
+	| tool sub <options> <arg>
+	| .fi
+	| .SH OPTIONS
+	| .TP
+	| \fB\-o, \-\-opt <value> [env: OPT]\fR
+	| This is an option
+	| .SH ARGUMENTS
+	| .TP
+	| \fB<arg>\fR
+	| This is an argument
+	| .SH SEE ALSO
+	| \fBtool\fR(1)
+	|
+	`))
+
+	markdown := &bytes.Buffer{}
+	err = WriteMarkdown(markdown, cmd)
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, markdown.String(), shorthand.Multiline(`
+	| # tool
+	|
+	| The tool
+	|
+	| `+"```"+`
+	| tool <command> ...
+	| `+"```"+`
+	|
+	| A prologue.
+	|
+	| ### Commands
+	|
+	| - [`+"`sub, s`"+`](#tool-sub): A subcommand
+	|
+	| An epilogue.
+	|
+	| ## tool sub
+	|
+	| A subcommand
+	|
+	| `+"```"+`
+	| tool sub <options> <arg>
+	| `+"```"+`
+	|
+	| ### Options
+	|
+	| - `+"`-o, --opt <value> [env: OPT]`"+`: This is an option
+	|
+	| ### Arguments
+	|
+	| - `+"`<arg>`"+`: This is an argument
+	|
+	`))
+
+	assert.Equal(t, docsRoffQuote(`A\B "C"`), `"A\eB ""C"""`)
+}
+
+func TestContextSignal(t *testing.T) {
+	exited := make(chan int, 1)
+	signalExit = func(code int) {
+		exited <- code
+	}
+	defer func() {
+		signalExit = os.Exit
+	}()
+
+	var code int
+	cmd := NewContext("test", "", func(ctx context.Context, _ *struct{}) error {
+		process, _ := os.FindProcess(os.Getpid())
+		process.Signal(os.Interrupt)
+		<-ctx.Done()
+		process.Signal(os.Interrupt)
+		code = <-exited
+		return ctx.Err()
+	})
+
+	err := cmd.RunArgsContext(context.Background(), []string{})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, err.IsParseFailure, false)
+	assert.Equal(t, code, 130)
+}
+
+type legacySubcommand struct {
+	Subcommand
+	parent CommandImmutable
+}
+
+func (s *legacySubcommand) RunAsSubcommand(parent CommandImmutable, args []string) *Error {
+	s.parent = parent
+	return s.Subcommand.RunAsSubcommand(parent, args)
+}
+
+func TestSubcommandWithoutContext(t *testing.T) {
+	ran := false
+	legacy := &legacySubcommand{Subcommand: New("legacy", "", func(*struct{}) error {
+		ran = true
+		return nil
+	})}
+	cmd := Namespace("tool", "", Modify(func(command CommandMutable) {
+		command.AddSubcommand(legacy)
+	}))
+
+	assert.Equal(t, cmd.RunArgs([]string{"legacy"}), nil)
+	assert.Equal(t, ran, true)
+	assert.Equal(t, legacy.parent.Name(), "tool")
+}
+
+func TestSuggestions(t *testing.T) {
+	type Opts struct {
+		Verbose bool `flag:"-v, --verbose"`
+		Version bool `flag:"--version"`
+	}
+
+	cmd := Namespace("command", "",
+		New("deploy, d", "Deploy", func(_ *Opts) error {
+			return nil
+		}),
+		New("destroy", "Destroy", func(_ *Opts) error {
+			return nil
+		}),
+	)
+
+	err := cmd.RunArgs([]string{"deplyo"})
+	assert.Equal(t, err.Suggestions, []string{"deploy"})
+	assert.Equal(t, err.Error(), "invalid subcommand \"deplyo\" (did you mean \"deploy\"?)")
+
+	err = cmd.RunArgs([]string{"de"})
+	assert.Equal(t, err.Suggestions, []string{"d", "deploy", "destroy"})
+	assert.Equal(t, err.Error(), "invalid subcommand \"de\" (did you mean \"d\", \"deploy\", or \"destroy\"?)")
+
+	err = cmd.RunArgs([]string{"deploy", "--verbos"})
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Suggestions, []string{"--verbose", "--version"})
+	assert.Equal(t, err.Error(), "unknown flag \"--verbos\" (did you mean \"--verbose\" or \"--version\"?)")
+
+	err = cmd.RunArgs([]string{"deploy", "--xyz"})
+	assert.Equal(t, err.Suggestions, nil)
+	assert.Equal(t, err.Error(), "unknown flag \"--xyz\"")
+}
+
+func TestDefaultAndChoices(t *testing.T) {
+	type Opts struct {
+		Format string   `flag:"--format <format>" default:"json" choices:"json|yaml" help:"Output format"`
+		Tags   []string `flag:"--tag <tag>" default:"a,b" help:"Tags"`
+		Mode   string   `flag:"[mode]" choices:"fast|slow" help:"Mode"`
+	}
+
+	var opts *Opts
+	cmd := New("test", "", func(o *Opts) error {
+		opts = o
+		return nil
+	})
+
+	err := cmd.RunArgs([]string{})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{Format: "json", Tags: []string{"a", "b"}})
+
+	err = cmd.RunArgs([]string{"--format", "yaml", "--tag", "c", "fast"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{Format: "yaml", Tags: []string{"c"}, Mode: "fast"})
+
+	err = cmd.RunArgs([]string{"--format", "xml"})
+
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Error(), "invalid value \"xml\" for flag --format: must be one of \"json\", \"yaml\"")
+
+	err = cmd.RunArgs([]string{"medium"})
+
+	assert.Equal(t, err.Error(), "invalid argument \"medium\" for [mode]: must be one of \"fast\", \"slow\"")
+
+	assert.Equal(t, cmd.String(), shorthand.Multiline(`
+	| Usage: test <options> [mode]
+	|
+	| Options:
+	|   --format <format>
+	|       Output format (choices: json, yaml) (default: json)
+	|   --tag <tag>
+	|       Tags (default: a,b)
+	|
+	| Arguments:
+	|   [mode]
+	|       Mode (choices: fast, slow)
+	|
+	`))
+}
+
+func TestParseModeGNU(t *testing.T) {
+	type Opts struct {
+		All     bool     `flag:"-a, --all"`
+		Verbose bool     `flag:"-v, --verbose"`
+		Output  string   `flag:"-o, --output <file>"`
+		Args    []string `flag:"<args...>"`
+	}
+
+	var opts *Opts
+	cmd := New("test", "", func(o *Opts) error {
+		opts = o
+		return nil
+	})
+
+	err := cmd.RunArgs([]string{"file.txt", "--verbose", "-aofile", "--", "-x", "--all"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{
+		All:     true,
+		Verbose: true,
+		Output:  "file",
+		Args:    []string{"file.txt", "-x", "--all"},
+	})
+
+	err = cmd.RunArgs([]string{"-va", "-o", "a", "--output=b", "-o=c", "-", "-output", "d"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{
+		All:     true,
+		Verbose: true,
+		Output:  "d",
+		Args:    []string{"-"},
+	})
+
+	err = cmd.RunArgs([]string{"-vx"})
+	assert.Equal(t, err.Error(), "unknown flag \"-x\"")
+
+	err = cmd.RunArgs([]string{"--output"})
+	assert.Equal(t, err.Error(), "flag needs an argument: --output")
+
+	err = cmd.RunArgs([]string{"-vh"})
+	assert.ErrorIs(t, err, flag.ErrHelp)
+}
+
+func TestParseModeGo(t *testing.T) {
+	type Opts struct {
+		Verbose bool     `flag:"-v, --verbose"`
+		Args    []string `flag:"<args...>"`
+	}
+
+	var opts *Opts
+	cmd := New("test", "",
+		func(o *Opts) error {
+			opts = o
+			return nil
+		},
+		Modify(func(command CommandMutable) {
+			command.SetParseMode(ParseModeGo)
+		}),
+	)
+
+	err := cmd.RunArgs([]string{"-verbose", "file.txt", "--verbose"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{
+		Verbose: true,
+		Args:    []string{"file.txt", "--verbose"},
+	})
+}
+
+func TestPersistent(t *testing.T) {
+	type Global struct {
+		Verbose bool   `flag:"-v, --verbose" help:"Verbose output"`
+		Profile string `flag:"--profile <name>" default:"default" help:"Profile name"`
+	}
+
+	type Opts struct {
+		Force bool `flag:"-f, --force" help:"Force"`
+	}
+
+	var global *Global
+	var opts *Opts
+	cmd := Namespace("tool", "",
+		Persistent[Global](),
+		NewContext("deploy", "Deploy", func(ctx context.Context, o *Opts) error {
+			global = ContextPersistent[Global](ctx)
+			opts = o
+			return nil
+		}),
+	)
+
+	err := cmd.RunArgs([]string{"-v", "--profile", "prod", "deploy", "-f"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, global, &Global{Verbose: true, Profile: "prod"})
+	assert.Equal(t, opts, &Opts{Force: true})
+
+	err = cmd.RunArgs([]string{"deploy", "-vf", "--profile=dev"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, global, &Global{Verbose: true, Profile: "dev"})
+	assert.Equal(t, opts, &Opts{Force: true})
+
+	err = cmd.RunArgs([]string{"deploy"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, global, &Global{Profile: "default"})
+
+	err = cmd.RunArgs([]string{"--force", "deploy"})
+
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Error(), "unknown flag \"--force\"")
+
+	err = cmd.RunArgs([]string{"deploy", "--profil", "x"})
+
+	assert.Equal(t, err.Suggestions, []string{"--profile"})
+
+	deploy := shorthand.FirstSeqValue(cmd.Subcommands())
+
+	assert.Equal(t, deploy.String(), shorthand.Multiline(`
+	| Usage: tool deploy <options>
+	|
+	| Deploy
+	|
+	| Options:
+	|   -f, --force
+	|       Force
+	|
+	| Global options:
+	|   -v, --verbose
+	|       Verbose output
+	|   --profile <name>
+	|       Profile name (default: default)
+	|
+	`))
+}
+
+func TestPersistentRepeated(t *testing.T) {
+	type Global struct {
+		Tags []string `flag:"--tag <tag>" env:"TAGS" default:"x"`
+	}
+
+	var global *Global
+	env := map[string]string{}
+	cmd := Namespace("tool", "",
+		Persistent[Global](),
+		NewContext("sub", "", func(ctx context.Context, _ *struct{}) error {
+			global = ContextPersistent[Global](ctx)
+			return nil
+		}),
+		Modify(func(command CommandMutable) {
+			command.SetGetter(Get(func(name string) (string, bool) {
+				value, ok := env[name]
+				return value, ok
+			}))
+		}),
+	)
+
+	assert.Equal(t, cmd.RunArgs([]string{"--tag", "a", "sub", "--tag", "b"}), nil)
+	assert.Equal(t, global.Tags, []string{"a", "b"})
+
+	assert.Equal(t, cmd.RunArgs([]string{"sub"}), nil)
+	assert.Equal(t, global.Tags, []string{"x"})
+
+	env["TAGS"] = "e,f"
+
+	assert.Equal(t, cmd.RunArgs([]string{"sub"}), nil)
+	assert.Equal(t, global.Tags, []string{"e", "f"})
+
+	assert.Equal(t, cmd.RunArgs([]string{"--tag", "a", "sub", "--tag", "b"}), nil)
+	assert.Equal(t, global.Tags, []string{"a", "b"})
+}
+
+func TestPersistentIsolation(t *testing.T) {
+	type Global struct {
+		Labels map[string]string `flag:"--label <key=value>"`
+	}
+
+	type Opts struct {
+		Label string `flag:"--label <value>"`
+		Arg   string `flag:"[arg]"`
+	}
+
+	var global *Global
+	var opts *Opts
+	cmd := Namespace("tool", "",
+		Persistent[Global](),
+		NewContext("mid", "", func(ctx context.Context, o *Opts) error {
+			global = ContextPersistent[Global](ctx)
+			opts = o
+			return nil
+		}, New("sub", "", func(*struct{}) error { return nil })),
+	)
+
+	assert.Equal(t, cmd.RunArgs([]string{"--label", "a=1", "mid", "--label", "b=2", "x"}), nil)
+	assert.Equal(t, global.Labels, map[string]string{"a": "1"})
+	assert.Equal(t, opts, &Opts{Label: "b=2", Arg: "x"})
+}
+
+func TestPersistentGroups(t *testing.T) {
+	type Global struct {
+		A string `flag:"-a <value>" env:"A" required-any:"g"`
+		B string `flag:"-b <value>" required-any:"g"`
+	}
+
+	env := map[string]string{}
+	cmd := Namespace("tool", "",
+		Persistent[Global](),
+		New("sub", "", func(*struct{}) error { return nil }),
+		Modify(func(command CommandMutable) {
+			command.SetGetter(Get(func(name string) (string, bool) {
+				value, ok := env[name]
+				return value, ok
+			}))
+		}),
+	)
+
+	assert.Equal(t, cmd.RunArgs([]string{"-a", "x", "sub"}), nil)
+	assert.Equal(t, cmd.RunArgs([]string{"sub", "-b", "x"}), nil)
+
+	err := cmd.RunArgs([]string{"sub"})
+
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Error(), "one of -a or -b is required")
+
+	env["A"] = "x"
+
+	assert.Equal(t, cmd.RunArgs([]string{"sub"}), nil)
+
+	sub := shorthand.FirstSeqValue(cmd.Subcommands())
+
+	assert.RegexpMatch(t, sub.String(), `(?m)^Constraints:\n  -a, -b\n      At least one is required\n`)
+}
+
+func TestGroups(t *testing.T) {
+	type Opts struct {
+		File     string `flag:"-f, --file <path>" exclusive:"source" required-any:"source" help:"Source file"`
+		URL      string `flag:"--url <url>" exclusive:"source" required-any:"source" help:"Source URL"`
+		User     string `flag:"--user <name>" env:"USER" together:"auth" help:"User name"`
+		Password string `flag:"--password <secret>" together:"auth" help:"Password"`
+	}
+
+	getter := Get(func(name string) (string, bool) {
+		return "", false
+	})
+	cmd := New("test", "", func(_ *Opts) error {
+		return nil
+	}, Modify(func(command CommandMutable) {
+		command.SetGetter(getter)
+	}))
+
+	assert.Equal(t, cmd.RunArgs([]string{"-f", "a"}), nil)
+	assert.Equal(t, cmd.RunArgs([]string{"--url", "b", "--user", "u", "--password", "p"}), nil)
+
+	err := cmd.RunArgs([]string{})
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Error(), "one of --file or --url is required")
+
+	err = cmd.RunArgs([]string{"-f", "a", "--url", "b"})
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Error(), "--file and --url cannot be used together")
+
+	err = cmd.RunArgs([]string{"-f", "a", "--user", "u"})
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Error(), "--password must be used with --user")
+
+	getter = Get(func(name string) (string, bool) {
+		return "u", name == "USER"
+	})
+	cmd = New("test", "", func(_ *Opts) error {
+		return nil
+	}, Modify(func(command CommandMutable) {
+		command.SetGetter(getter)
+	}))
+
+	err = cmd.RunArgs([]string{"-f", "a"})
+	assert.Equal(t, err.Error(), "--password must be used with --user")
+
+	assert.Equal(t, cmd.String(), shorthand.Multiline(`
+	| Usage: test <options>
+	|
+	| Options:
+	|   -f, --file <path>
+	|       Source file
+	|   --url <url>
+	|       Source URL
+	|   --user <name> [env: USER]
+	|       User name
+	|   --password <secret>
+	|       Password
+	|
+	| Constraints:
+	|   --file, --url
+	|       Mutually exclusive
+	|   --file, --url
+	|       At least one is required
+	|   --user, --password
+	|       Must be used together
+	|
+	`))
+}
+
+func TestFlagKinds(t *testing.T) {
+	type Opts struct {
+		Verbose int            `flag:"-v, --verbose" count:"true" help:"Verbosity"`
+		Color   bool           `flag:"--color" negatable:"true" default:"true" help:"Colorize output"`
+		Labels  map[string]int `flag:"-l, --label <key=value>" default:"a=1" help:"Labels"`
+	}
+
+	var opts *Opts
+	cmd := New("test", "", func(o *Opts) error {
+		opts = o
+		return nil
+	})
+
+	err := cmd.RunArgs([]string{})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{Color: true, Labels: map[string]int{"a": 1}})
+
+	err = cmd.RunArgs([]string{"-vvv", "--no-color", "-l", "b=2", "--label=c=3", "-v"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{Verbose: 4, Color: false, Labels: map[string]int{"b": 2, "c": 3}})
+
+	err = cmd.RunArgs([]string{"--verbose=2", "--no-color", "--color"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{Verbose: 2, Color: true, Labels: map[string]int{"a": 1}})
+
+	err = cmd.RunArgs([]string{"--no-color=false"})
+	assert.Equal(t, err.Error(), "flag does not take a value: --no-color")
+
+	err = cmd.RunArgs([]string{"-l", "b"})
+	assert.Equal(t, err.Error(), "invalid value \"b\" for flag -l: expected key=value")
+
+	err = cmd.RunArgs([]string{"-l", "b=x"})
+	assert.Equal(t, err.IsParseFailure, true)
+
+	err = cmd.RunArgs([]string{"--no-colr"})
+	assert.Equal(t, err.Suggestions, []string{"--no-color"})
+
+	assert.Equal(t, cmd.String(), shorthand.Multiline(`
+	| Usage: test <options>
+	|
+	| Options:
+	|   -v, --verbose
+	|       Verbosity (repeatable)
+	|   --[no-]color
+	|       Colorize output (default: true)
+	|   -l, --label <key=value>
+	|       Labels (default: a=1)
+	|
+	`))
+
+	type BadCount struct {
+		Verbose string `flag:"-v" count:"true"`
+	}
+
+	badCmd := New("test", "", func(*BadCount) error { return nil })
+
+	for _, args := range [][]string{{"-v"}, {}} {
+		err = badCmd.RunArgs(args)
+
+		assert.Equal(t, err.IsParseFailure, true)
+		assert.Equal(t, err.Error(), "count flag -v must be an integer, not string")
+	}
+
+	assert.Equal(t, NewField(reflect.TypeFor[BadCount](), reflect.TypeFor[BadCount]().Field(0)).IsCount(), false)
+}
+
+func TestNestedStructs(t *testing.T) {
+	type Database struct {
+		Host string `flag:"-h, --host <host>" env:"HOST" default:"localhost" help:"Database host"`
+		Port int    `flag:"--port <port>" help:"Database port"`
+	}
+
+	type Opts struct {
+		Verbose bool     `flag:"-v, --verbose" help:"Verbose output"`
+		Primary Database `flag-prefix:"db-" env-prefix:"DB_" help:"Primary database"`
+		Replica Database `flag-prefix:"replica-" help:"Replica database"`
+	}
+
+	var opts *Opts
+	cmd := New("test", "", func(o *Opts) error {
+		opts = o
+		return nil
+	}, Modify(func(command CommandMutable) {
+		command.SetGetter(Get(func(name string) (string, bool) {
+			return "env-host", name == "DB_HOST"
+		}))
+	}))
+
+	err := cmd.RunArgs([]string{"-v", "--replica-host", "replica", "--db-port=1", "--replica-port", "2"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{
+		Verbose: true,
+		Primary: Database{Host: "env-host", Port: 1},
+		Replica: Database{Host: "replica", Port: 2},
+	})
+
+	err = cmd.RunArgs([]string{"--host", "x"})
+	assert.Equal(t, err.Error(), "unknown flag \"--host\"")
+
+	assert.Equal(t, cmd.String(), shorthand.Multiline(`
+	| Usage: test <options>
+	|
+	| Options:
+	|   -v, --verbose
+	|       Verbose output
+	|
+	| Primary database:
+	|   --db-host <host> [env: DB_HOST]
+	|       Database host (default: localhost)
+	|   --db-port <port>
+	|       Database port
+	|
+	| Replica database:
+	|   --replica-host <host> [env: HOST]
+	|       Database host (default: localhost)
+	|   --replica-port <port>
+	|       Database port
+	|
+	`))
+
+	type ShortValue struct {
+		DB struct {
+			Level string `flag:"-l <level>"`
+		} `flag-prefix:"db-"`
+	}
+
+	assert.Panic(t, func() {
+		New("test", "", func(*ShortValue) error { return nil }).RunArgs([]string{"x"})
+	})
+
+	type ShortBool struct {
+		DB struct {
+			V bool `flag:"-v"`
+		} `flag-prefix:"db-"`
+	}
+
+	assert.Panic(t, func() {
+		New("test", "", func(*ShortBool) error { return nil }).RunArgs(nil)
+	})
+}
+
+func TestHiddenAndDeprecated(t *testing.T) {
+	type Opts struct {
+		Region string `flag:"-r, --region <region>" help:"Region"`
+		Zone   string `flag:"--zone <zone>" forward:"--region" help:"Zone"`
+		Debug  bool   `flag:"--debug" hidden:"true" help:"Debug output"`
+		Legacy bool   `flag:"--legacy" deprecated:"it has no effect" help:"Legacy mode"`
+	}
+
+	var opts *Opts
+	output := &bytes.Buffer{}
+	action := func(o *Opts) error {
+		opts = o
+		return nil
+	}
+	cmd := Namespace("tool", "",
+		New("deploy", "Deploy", action),
+		New("push", "Push", action, Deprecated("use deploy instead")),
+		New("debug", "Debug", action, Hidden()),
+		Modify(func(command CommandMutable) {
+			command.SetOutput(output)
+		}),
+	)
+
+	err := cmd.RunArgs([]string{"deploy", "--zone", "west", "--debug", "--legacy"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{Region: "west", Debug: true, Legacy: true})
+	assert.Equal(t, output.String(), "warning: flag --zone is deprecated: use --region instead\n"+
+		"warning: flag --legacy is deprecated: it has no effect\n")
+
+	output.Reset()
+	err = cmd.RunArgs([]string{"push"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, output.String(), "warning: command \"tool push\" is deprecated: use deploy instead\n")
+
+	err = cmd.RunArgs([]string{"debug"})
+
+	assert.Equal(t, err, nil)
+
+	err = cmd.RunArgs([]string{"pus"})
+
+	assert.Equal(t, err.Suggestions, nil)
+
+	assert.Equal(t, cmd.String(), shorthand.Multiline(`
+	| Usage: tool <command> ...
+	|
+	| Commands:
+	|   deploy
+	|       Deploy
+	|
+	`))
+
+	assert.Equal(t, shorthand.FirstSeqValue(cmd.Subcommands()).String(), shorthand.Multiline(`
+	| Usage: tool deploy <options>
+	|
+	| Deploy
+	|
+	| Options:
+	|   -r, --region <region>
+	|       Region
+	|
+	`))
+}
+
+func TestSchema(t *testing.T) {
+	type Global struct {
+		Verbose bool `flag:"-v, --verbose" help:"Verbose output"`
+	}
+
+	type Opts struct {
+		Tags  []string `flag:"-t, --tag <tag>" env:"TAGS" help:"Tags" validate:"dive,required"`
+		Input string   `flag:"<input>" help:"Input file"`
+		Extra []string `flag:"[extra...]" max-args:"3" help:"Extra files"`
+	}
+
+	cmd := Namespace("tool", "Tool",
+		Persistent[Global](),
+		New("run, r", "Run", func(_ *Opts) error {
+			return nil
+		}),
+		SchemaFlag("--schema"),
+	)
+
+	schema := NewSchema(cmd)
+
+	assert.Equal(t, schema.Name, "tool")
+	assert.Equal(t, len(schema.Fields), 2)
+	assert.Equal(t, schema.Fields[1].Name, "Verbose")
+	assert.Equal(t, schema.Fields[1].IsPersistent, true)
+	assert.Equal(t, schema.Subcommands[0].Aliases, []string{"r"})
+	assert.Equal(t, schema.Subcommands[0].Fields[0], SchemaField{
+		Name:        "Tags",
+		Flag:        "-t, --tag <tag>",
+		FlagNames:   []string{"t", "tag"},
+		IsNamedFlag: true,
+		Help:        "Tags",
+		Type:        "[]string",
+		IsSlice:     true,
+		Env:         "TAGS",
+		Choices:     []string{},
+		Validate:    "dive,required",
+	})
+	assert.Equal(t, schema.Subcommands[0].Fields[1].Type, "string")
+	assert.Equal(t, schema.Subcommands[0].Fields[1].IsRequired, true)
+	assert.Equal(t, schema.Subcommands[0].Fields[1].MinArgs, 1)
+	assert.Equal(t, schema.Subcommands[0].Fields[1].MaxArgs, 1)
+	assert.Equal(t, schema.Subcommands[0].Fields[2].IsRequired, false)
+	assert.Equal(t, schema.Subcommands[0].Fields[2].MinArgs, 0)
+	assert.Equal(t, schema.Subcommands[0].Fields[2].MaxArgs, 3)
+	assert.Equal(t, schema.Usage, []string{"tool <command> ..."})
+	assert.Equal(t, schema.Subcommands[0].Usage, []string{"tool run <options> <input> [extra...]"})
+
+	b := &bytes.Buffer{}
+	assert.Equal(t, WriteSchema(b, cmd), nil)
+	assert.RegexpMatch(t, b.String(), `(?s)^\{\n  "name": "tool",\n.*"subcommands": \[\n    \{\n      "name": "run",`)
+
+	stdout := os.Stdout
+	file, _ := os.CreateTemp(t.TempDir(), "stdout")
+	os.Stdout = file
+	err := cmd.RunArgs([]string{"--schema"})
+	os.Stdout = stdout
+
+	assert.Equal(t, err, nil)
+	content, _ := os.ReadFile(file.Name())
+	assert.Equal(t, string(content), b.String())
+}
+
+func TestHooks(t *testing.T) {
+	type Opts struct {
+		Fail bool `flag:"--fail"`
+		Stop bool `flag:"--stop"`
+	}
+
+	calls := []string{}
+	cmd := Namespace("tool", "",
+		PreRun(func(_ context.Context, command CommandImmutable, opts any) error {
+			calls = append(calls, "pre "+command.Fullname())
+
+			if opts.(*Opts).Stop {
+				return NewError(errors.New("stopped"), true)
+			}
+
+			return nil
+		}),
+		PostRun(func(_ context.Context, command CommandImmutable, _ any, err error) error {
+			calls = append(calls, "post "+command.Fullname())
+
+			if err != nil {
+				return fmt.Errorf("wrapped: %w", err)
+			}
+
+			return nil
+		}),
+		New("run", "", func(opts *Opts) error {
+			calls = append(calls, "run")
+
+			if opts.Fail {
+				return errors.New("failed")
+			}
+
+			return nil
+		}, Wrap(func(next Action) Action {
+			return func(ctx context.Context, command CommandImmutable, opts any) error {
+				calls = append(calls, "wrap")
+				return next(ctx, command, opts)
+			}
+		})),
+		Completion(),
+		Version(),
+		Modify(func(command CommandMutable) {
+			command.SetOutput(io.Discard)
+		}),
+	)
+
+	err := cmd.RunArgs([]string{"run"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, calls, []string{"pre tool run", "wrap", "run", "post tool run"})
+
+	// Built-in subcommands and namespaces are not wrapped, so hooks can assume
+	// the options type.
+	stdout := os.Stdout
+	os.Stdout, _ = os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	defer func() {
+		os.Stdout = stdout
+	}()
+
+	calls = nil
+
+	assert.Equal(t, cmd.RunArgs([]string{"completion", "bash"}), nil)
+	assert.Equal(t, cmd.RunArgs([]string{"version"}), nil)
+	assert.Equal(t, calls, nil)
+
+	err = cmd.RunArgs([]string{})
+
+	assert.Equal(t, err.Error(), "missing required subcommand")
+	assert.Equal(t, calls, nil)
+
+	calls = nil
+	err = cmd.RunArgs([]string{"run", "--fail"})
+
+	assert.Equal(t, err.Error(), "wrapped: failed")
+	assert.Equal(t, err.IsParseFailure, false)
+	assert.Equal(t, calls, []string{"pre tool run", "wrap", "run", "post tool run"})
+
+	calls = nil
+	err = cmd.RunArgs([]string{"run", "--stop"})
+
+	// The pre-run hook was added first, so it also short-circuits the
+	// post-run hook.
+	assert.Equal(t, err.Error(), "stopped")
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, calls, []string{"pre tool run"})
+}
+
+func TestPrompt(t *testing.T) {
+	type Opts struct {
+		Name   string `flag:"--name <name>" prompt:"Name" validate:"required"`
+		Format string `flag:"--format <format>" prompt:"Format" choices:"json|yaml" default:"json"`
+		Count  int    `flag:"--count <n>" prompt:"Count"`
+	}
+
+	answers := map[string]string{}
+	prompted := []string{}
+	interactive := true
+	prompter := Prompt(func(field Field) (string, bool, error) {
+		prompted = append(prompted, field.Prompt())
+		return answers[field.Name()], interactive, nil
+	})
+
+	var opts *Opts
+	cmd := New("test", "", func(o *Opts) error {
+		opts = o
+		return nil
+	}, Modify(func(command CommandMutable) {
+		command.SetPrompter(prompter)
+	}))
+
+	answers = map[string]string{"Name": "alice", "Count": "3"}
+	err := cmd.RunArgs([]string{"--format", "yaml"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, prompted, []string{"Name", "Count"})
+	assert.Equal(t, opts, &Opts{Name: "alice", Format: "yaml", Count: 3})
+
+	answers = map[string]string{"Name": "bob", "Format": "xml"}
+	err = cmd.RunArgs([]string{})
+
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Error(), "invalid value \"xml\" for --format: must be one of \"json\", \"yaml\"")
+
+	answers = map[string]string{"Count": "x"}
+	err = cmd.RunArgs([]string{"--name", "carol"})
+
+	assert.Equal(t, err.IsParseFailure, true)
+
+	answers = map[string]string{}
+	err = cmd.RunArgs([]string{})
+
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.RegexpMatch(t, err.Error(), `required`)
+
+	prompted = nil
+	interactive = false
+	err = cmd.RunArgs([]string{"--name", "dave"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, prompted, []string{"Format"})
+	assert.Equal(t, opts, &Opts{Name: "dave", Format: "json"})
+
+	assert.Equal(t, prompterChoice("2", []string{"json", "yaml"}), "yaml")
+	assert.Equal(t, prompterChoice("1", []string{"3", "2", "1"}), "1")
+	assert.Equal(t, prompterChoice("3", []string{"10", "20"}), "3")
+}
+
+func TestResponseFiles(t *testing.T) {
+	type Opts struct {
+		Verbose bool     `flag:"-v, --verbose"`
+		Paths   []string `flag:"<paths...>"`
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(dir+"/args.txt", []byte("# Comment\n-v 'a b' \"c \\\"d\\\"\" e\\ f\n@"+dir+"/more.txt\n"), 0o644)
+	os.WriteFile(dir+"/more.txt", []byte("g # trailing comment\n"), 0o644)
+	os.WriteFile(dir+"/loop.txt", []byte("@"+dir+"/loop.txt"), 0o644)
+
+	var opts *Opts
+	cmd := New("test", "", func(o *Opts) error {
+		opts = o
+		return nil
+	}, ResponseFiles())
+
+	err := cmd.RunArgs([]string{"@" + dir + "/args.txt", "h", "@@i", "--", "@j"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{Verbose: true, Paths: []string{"a b", "c \"d\"", "e f", "g", "h", "@i", "@j"}})
+
+	err = cmd.RunArgs([]string{"@" + dir + "/missing.txt"})
+
+	assert.Equal(t, err.IsParseFailure, true)
+
+	err = cmd.RunArgs([]string{"@" + dir + "/loop.txt"})
+
+	assert.RegexpMatch(t, err.Error(), `nested too deeply`)
+}
+
+func TestFileSources(t *testing.T) {
+	type Opts struct {
+		Data   []byte     `flag:"--data <data>"`
+		Reader io.Reader  `flag:"--reader <reader>"`
+		Source FileSource `flag:"--source <path>"`
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(dir+"/data.txt", []byte("file data"), 0o644)
+
+	var data, reader, source string
+	var opts *Opts
+	cmd := New("test", "", func(o *Opts) error {
+		opts = o
+		data = string(o.Data)
+		b, _ := io.ReadAll(o.Reader)
+		reader = string(b)
+		b, _ = io.ReadAll(o.Source)
+		source = string(b)
+		return nil
+	})
+
+	err := cmd.RunArgs([]string{"--data", "@" + dir + "/data.txt", "--reader", "@" + dir + "/data.txt", "--source", dir + "/data.txt"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, data, "file data")
+	assert.Equal(t, reader, "file data")
+	assert.Equal(t, source, "file data")
+	assert.Equal(t, opts.Source.file, nil)
+
+	err = cmd.RunArgs([]string{"--data", "literal", "--reader", "text", "--source", "@" + dir + "/data.txt"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, data, "literal")
+	assert.Equal(t, reader, "text")
+	assert.Equal(t, source, "file data")
+
+	err = cmd.RunArgs([]string{"--source", dir + "/missing.txt"})
+
+	assert.Equal(t, err.IsParseFailure, false)
+	assert.RegexpMatch(t, err.Error(), `^failed opening --source: `)
+}
+
+func TestPlugins(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(dir+"/tool-hello", []byte("#!/bin/sh\necho \"hello $*\" > \"$(dirname \"$0\")/out.txt\"\nexit 3\n"), 0o755)
+	os.WriteFile(dir+"/tool-data.txt", []byte(""), 0o644)
+	os.WriteFile(dir+"/tool-killed", []byte("#!/bin/sh\nkill -9 $$\n"), 0o755)
+
+	code := -1
+	output := &bytes.Buffer{}
+	cmd := Namespace("tool", "",
+		Plugins(),
+		Modify(func(command CommandMutable) {
+			command.SetGetter(Get(func(name string) (string, bool) {
+				return dir, name == "PATH"
+			}))
+			command.SetOutput(output)
+			command.SetExit(func(newCode int) {
+				code = newCode
+			})
+		}),
+	)
+
+	cmd.RunArgsAndExit([]string{"hello", "a", "--b"})
+
+	content, _ := os.ReadFile(dir + "/out.txt")
+	assert.Equal(t, string(content), "hello a --b\n")
+	assert.Equal(t, code, 3)
+	assert.Equal(t, output.String(), "")
+
+	err := cmd.RunArgs([]string{"data.txt"})
+
+	assert.Equal(t, err.Error(), "invalid subcommand \"data.txt\"")
+
+	assert.Equal(t, cmd.String(), shorthand.Multiline(`
+	| Usage: tool <command> ...
+	|
+	| Plugin commands:
+	|   hello
+	|       `+dir+`/tool-hello
+	|   killed
+	|       `+dir+`/tool-killed
+	|
+	`))
+
+	cmd.RunArgsAndExit([]string{"killed"})
+
+	assert.Equal(t, code, 128+9)
+
+	pathLookups := 0
+	err = Namespace("tool", "", New("sub", "", func(*struct{}) error { return nil }), Modify(func(command CommandMutable) {
+		command.SetGetter(Get(func(name string) (string, bool) {
+			if name == "PATH" {
+				pathLookups++
+			}
+
+			return dir, name == "PATH"
+		}))
+	})).RunArgs([]string{"hello"})
+
+	assert.Equal(t, err.Error(), "invalid subcommand \"hello\"")
+	assert.Equal(t, pathLookups, 0)
+}
+
+func TestExitCodes(t *testing.T) {
+	type Opts struct {
+		Name string `flag:"<name>"`
+	}
+
+	code := -1
+	output := &bytes.Buffer{}
+	cmd := New("test", "", func(opts *Opts) error {
+		switch opts.Name {
+		case "missing":
+			return fmt.Errorf("lookup: %w", NewExitError(4, errors.New("not found"), "Check the name.\nNames are case sensitive."))
+		case "conflict":
+			return ExitErrorf(5, "conflict: %s", opts.Name)
+		case "hint":
+			return NewExitError(0, errors.New("failed"), "Try again.")
+		}
+
+		return nil
+	}, Modify(func(command CommandMutable) {
+		command.SetOutput(output)
+		command.SetExit(func(newCode int) {
+			code = newCode
+		})
+	}))
+
+	err := cmd.RunArgs([]string{"missing"})
+
+	assert.Equal(t, err.ExitCode(), 4)
+	assert.Equal(t, err.Hint(), "Check the name.\nNames are case sensitive.")
+
+	cmd.RunArgsAndExit([]string{"missing"})
+
+	assert.Equal(t, code, 4)
+	assert.Equal(t, output.String(), "lookup: not found\nhint: Check the name.\nhint: Names are case sensitive.\n")
+
+	output.Reset()
+	cmd.RunArgsAndExit([]string{"conflict"})
+
+	assert.Equal(t, code, 5)
+	assert.Equal(t, output.String(), "conflict: conflict\n")
+
+	output.Reset()
+	cmd.RunArgsAndExit([]string{"hint"})
+
+	assert.Equal(t, code, ExitCodeActionFailure)
+	assert.Equal(t, output.String(), "failed\nhint: Try again.\n")
+
+	cmd.RunArgsAndExit([]string{"ok"})
+
+	assert.Equal(t, code, ExitCodeSuccess)
+
+	cmd.RunArgsAndExit([]string{"a", "b"})
+
+	assert.Equal(t, code, ExitCodeParseFailure)
+	err = New("test", "", func(*struct{}) error {
+		return NewExitError(7, nil, "Check the logs.")
+	}).RunArgs(nil)
+
+	assert.Equal(t, err.Error(), "exit status 7")
+	assert.Equal(t, err.ExitCode(), 7)
+	assert.Equal(t, err.Hint(), "Check the logs.")
+	assert.Equal(t, NewError(nil, false).Error(), "exit status 2")
+	assert.Equal(t, NewError(nil, true).Error(), "exit status 1")
+}
+
+func TestShell(t *testing.T) {
+	type Opts struct {
+		Verbose bool   `flag:"-v, --verbose" help:"Verbose output"`
+		Name    string `flag:"<name>" help:"Name"`
+	}
+
+	output := &bytes.Buffer{}
+	names := []string{}
+	cmd := Namespace("tool", "Tool",
+		New("greet", "Greet", func(opts *Opts) error {
+			if opts.Name == "fail" {
+				return NewExitError(3, errors.New("failed"), "Try again.")
+			}
+
+			names = append(names, opts.Name)
+			return nil
+		}),
+		Modify(func(command CommandMutable) {
+			command.SetOutput(output)
+		}),
+		Shell(),
+	)
+
+	stdin := os.Stdin
+	file, _ := os.CreateTemp(t.TempDir(), "stdin")
+	file.WriteString("greet 'a b'\n\n# comment\ngreet fail\ngreet -x\nhelp greet\nexit\ngreet c\n")
+	file.Seek(0, io.SeekStart)
+	os.Stdin = file
+	err := cmd.RunArgs([]string{"shell"})
+	os.Stdin = stdin
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, names, []string{"a b"})
+	assert.Equal(t, output.String(), shorthand.Multiline(`
+	| failed
+	| hint: Try again.
+	| unknown flag "-x"
+	| Usage: tool greet <options> <name>
+	|
+	| Greet
+	|
+	| Options:
+	|   -v, --verbose
+	|       Verbose output
+	|
+	| Arguments:
+	|   <name>
+	|       Name
+	|
+	`)+"\n")
+
+	assert.Equal(t, shellCandidates(&cmd, []string{}), []string{"help", "exit", "greet", "shell"})
+	assert.Equal(t, shellCandidates(&cmd, []string{"greet"}), []string{"-v", "--verbose"})
+	assert.Equal(t, shellCandidates(&cmd, []string{"help"}), []string{"greet", "shell"})
+}
+
+func TestShellNested(t *testing.T) {
+	type Global struct {
+		Tags []string `flag:"--tag <tag>"`
+	}
+
+	output := &bytes.Buffer{}
+	calls := []string{}
+	record := func(ctx context.Context, _ *struct{}) error {
+		calls = append(calls, fmt.Sprintf("%s %v", ContextCommand(ctx).Fullname(), ContextPersistent[Global](ctx).Tags))
+		return nil
+	}
+	cmd := Namespace("tool", "",
+		Persistent[Global](),
+		Namespace("db", "",
+			NewContext("ls", "", record),
+			NewContext("exit", "", record),
+			Shell(),
+		),
+		Modify(func(command CommandMutable) {
+			command.SetOutput(output)
+		}),
+	)
+
+	stdin := os.Stdin
+	file, _ := os.CreateTemp(t.TempDir(), "stdin")
+	file.WriteString("ls --tag b\nls\nshell\nexit\nquit\nls\n")
+	file.Seek(0, io.SeekStart)
+	os.Stdin = file
+	err := cmd.RunArgs([]string{"--tag", "a", "db", "shell"})
+	os.Stdin = stdin
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, calls, []string{"tool db ls [a b]", "tool db ls [a]", "tool db exit [a]"})
+	assert.Equal(t, output.String(), "already in a shell\n")
+}
+
+func TestVersion(t *testing.T) {
+	BuildVersion = "v1.2.3"
+	BuildRevision = "abc123"
+	BuildDirty = "true"
+	BuildTime = "2026-01-02T03:04:05Z"
+	defer func() {
+		BuildVersion, BuildRevision, BuildDirty, BuildTime = "", "", "", ""
+	}()
+
+	info := ReadVersionInfo()
+
+	assert.Equal(t, info.Version, "v1.2.3")
+	assert.Equal(t, info.Revision, "abc123")
+	assert.Equal(t, info.Dirty, true)
+	assert.Equal(t, info.Time, "2026-01-02T03:04:05Z")
+
+	b := &bytes.Buffer{}
+	WriteVersion(b, "tool", false)
+
+	assert.RegexpMatch(t, b.String(), `^tool v1\.2\.3\n(Module: .*\n)?Revision: abc123 \(dirty\)\nBuilt: 2026-01-02T03:04:05Z\nGo: go.*\n$`)
+
+	output := &bytes.Buffer{}
+	cmd := Namespace("tool, t", "",
+		Version(),
+		New("sub", "", func(*struct{}) error { return nil }),
+		Modify(func(command CommandMutable) {
+			command.SetOutput(output)
+		}),
+	)
+
+	for _, args := range [][]string{{"--version", "--json"}, {"version", "--json"}, {"sub", "--version", "--json"}} {
+		output.Reset()
+		err := cmd.RunArgs(args)
+
+		assert.Equal(t, err, nil)
+		assert.RegexpMatch(t, output.String(), `(?s)^\{\n  "module": .*"version": "v1\.2\.3",\n  "revision": "abc123",\n  "dirty": true,`)
+	}
+
+	err := cmd.RunArgs([]string{"--version", "--bogus"})
+
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.RegexpMatch(t, cmd.RunArgs([]string{"sub", "--help"}).Command().String(), `(?m)^  --version\n      Print version information\n`)
+
+	type Opts struct {
+		Verbose bool   `flag:"-v" help:"Verbose"`
+		Name    string `flag:"<name>" help:"Name"`
+	}
+
+	output.Reset()
+	leaf := New("greet", "", func(*Opts) error { return nil }, Version(), Modify(func(command CommandMutable) {
+		command.SetOutput(output)
+	}))
+	err = leaf.RunArgs([]string{"-v", "--version"})
+
+	assert.Equal(t, err, nil)
+	assert.RegexpMatch(t, output.String(), `^greet v1\.2\.3\n`)
+	assert.RegexpMatch(t, leaf.String(), `(?m)^Usage: greet <options> <name>\n(.*\n)*Options:\n  -v  Verbose\n  --version\n      Print version information\n`)
+
+	script := &bytes.Buffer{}
+	assert.Equal(t, WriteCompletion(script, leaf, "bash"), nil)
+	assert.RegexpMatch(t, script.String(), `--version`)
+}
+
+func TestRender(t *testing.T) {
+	type record struct {
+		Name   string `column:"NAME" json:"name" yaml:"name"`
+		Size   int    `column:"SIZE" json:"size" yaml:"size"`
+		Secret string `json:"-" yaml:"-"`
+	}
+
+	records := []record{{"alpha", 1, "x"}, {"beta-gamma", 22, "y"}}
+	render := func(options RenderOptions) string {
+		b := &bytes.Buffer{}
+		renderer, err := NewRenderer[record](b, options)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, renderer.RenderAll(records), nil)
+		assert.Equal(t, renderer.Close(), nil)
+		return b.String()
+	}
+
+	assert.Equal(t, render(RenderOptions{Output: "table"}), "NAME        SIZE\nalpha       1\nbeta-gamma  22\n")
+	assert.Equal(t, render(RenderOptions{Output: "json"}), "[\n  {\n    \"name\": \"alpha\",\n    \"size\": 1\n  },\n  {\n    \"name\": \"beta-gamma\",\n    \"size\": 22\n  }\n]\n")
+	assert.Equal(t, render(RenderOptions{Output: "ndjson"}), "{\"name\":\"alpha\",\"size\":1}\n{\"name\":\"beta-gamma\",\"size\":22}\n")
+	assert.Equal(t, render(RenderOptions{Output: "yaml"}), "- name: alpha\n  size: 1\n- name: beta-gamma\n  size: 22\n")
+	assert.Equal(t, render(RenderOptions{Output: "template", Template: "{{.Name}}={{.Size}}"}), "alpha=1\nbeta-gamma=22\n")
+
+	_, err := NewRenderer[record](io.Discard, RenderOptions{Output: "template"})
+	assert.NotEqual(t, err, nil)
+
+	type listOpts struct {
+		RenderOptions
+		Prefix string `flag:"--prefix <text>"`
+	}
+
+	output := &bytes.Buffer{}
+	cmd := NewRender("list", "", func(_ context.Context, opts *listOpts, render func(string) error) error {
+		for _, name := range []string{"one", "two"} {
+			if err := render(opts.Prefix + name); err != nil {
+				return err
+			}
+		}
+
+		if opts.Prefix == "fail-" {
+			return errors.New("failed")
+		}
+
+		return nil
+	}, Modify(func(command CommandMutable) {
+		command.SetOutput(output)
+	}))
+
+	cmdErr := cmd.RunArgs([]string{"-o", "ndjson", "--prefix", "x-"})
+
+	assert.Equal(t, cmdErr, nil)
+	assert.Equal(t, output.String(), "\"x-one\"\n\"x-two\"\n")
+
+	output.Reset()
+	cmdErr = cmd.RunArgs([]string{"-o", "json", "--prefix", "fail-"})
+
+	assert.Equal(t, cmdErr.Error(), "failed")
+	assert.Equal(t, output.String(), "[\n  \"fail-one\",\n  \"fail-two\"\n]\n")
+
+	cmdErr = cmd.RunArgs([]string{"--output", "xml"})
+	assert.Equal(t, cmdErr.IsParseFailure, true)
+
+	assert.Panic(t, func() {
+		NewRender("bad", "", func(_ context.Context, _ *struct{}, _ func(string) error) error { return nil })
+	})
+}
+
+func TestHelpFormatting(t *testing.T) {
+	b := NewHelperBuilder()
+	b.Width = 30
+	b.WriteParagraph("The quick brown fox jumps over the lazy dog.")
+	b.WriteListHeading("Options:")
+	b.WriteListItem("--name <value>", "Name of the thing to create, which must be unique")
+	b.WriteListItem("-v", "Verbose")
+
+	assert.Equal(t, b.String(), shorthand.Multiline(`
+	| The quick brown fox jumps over
+	| the lazy dog.
+	|
+	| Options:
+	|   --name <value>
+	|       Name of the thing to
+	|       create, which must be
+	|       unique
+	|   -v  Verbose
+	`)+"\n")
+
+	b.Compact = true
+	b.WriteListHeading("Options:")
+	b.WriteListItem("--name <value>", "Name of the thing to create")
+	b.WriteListItem("-v", "Verbose")
+
+	assert.Equal(t, b.String(), shorthand.Multiline(`
+	| Options:
+	|   --name <value>  Name of the
+	|                   thing to
+	|                   create
+	|   -v              Verbose
+	`)+"\n")
+
+	b.Compact = false
+	b.WriteParagraph("Examples:\n\n    tool   a   b\n\ttool c")
+	b.WriteListItem("--name <value>", "Name:\n  one  two")
+
+	assert.Equal(t, b.String(), "Examples:\n\n    tool   a   b\n\ttool c\n\n  --name <value>\n      Name:\n        one  two\n")
+
+	b = NewHelperBuilder()
+	b.Style = true
+	b.WriteListHeading("Commands:")
+	b.WriteListItem("run", "Run it")
+
+	assert.Equal(t, b.String(), "\x1b[1mCommands:\x1b[0m\n  \x1b[36mrun\x1b[0m\n      Run it\n")
+
+	b = NewTerminalHelperBuilder(&bytes.Buffer{}, Get(func(string) (string, bool) { return "100", true }))
+
+	assert.Equal(t, b.Width, 0)
+	assert.Equal(t, b.Style, false)
+
+	type opts struct {
+		Verbose bool   `flag:"-v, --verbose" help:"Verbose output"`
+		Name    string `flag:"--name <value>" help:"Name"`
+	}
+
+	cmd := New("tool", "Do things", func(*opts) error { return nil }, Modify(func(command CommandMutable) {
+		command.SetHelper(HelperCompact)
+		command.SetOutput(&bytes.Buffer{})
+	}))
+
+	assert.Equal(t, cmd.String(), shorthand.Multiline(`
+	| Usage: tool <options>
+	|
+	| Do things
+	|
+	| Options:
+	|   -v, --verbose   Verbose output
+	|   --name <value>  Name
+	`)+"\n")
+}
+
+func TestArity(t *testing.T) {
+	type Opts struct {
+		Src   string   `flag:"<src>" help:"Source"`
+		Dst   string   `flag:"<dst>" help:"Destination"`
+		Files []string `flag:"<files...>" min-args:"2" max-args:"3" help:"Files"`
+	}
+
+	var opts *Opts
+	cmd := New("copy", "", func(o *Opts) error {
+		opts = o
+		return nil
+	})
+
+	err := cmd.RunArgs([]string{})
+
+	assert.Equal(t, err.IsParseFailure, true)
+	assert.Equal(t, err.Error(), "missing argument <src>")
+
+	err = cmd.RunArgs([]string{"a", "b", "c"})
+
+	assert.Equal(t, err.Error(), "too few arguments for <files...>: expected at least 2")
+
+	err = cmd.RunArgs([]string{"a", "b", "c", "d", "e", "f"})
+
+	assert.Equal(t, err.Error(), "too many arguments for <files...>: expected at most 3")
+
+	err = cmd.RunArgs([]string{"a", "b", "c", "d"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{Src: "a", Dst: "b", Files: []string{"c", "d"}})
+	assert.RegexpMatch(t, cmd.String(), `^Usage: copy <src> <dst> <files\.\.\.>\n`)
+
+	type NoHelpOpts struct {
+		Verbose bool   `flag:"-v" help:"Verbose"`
+		Src     string `flag:"<src>"`
+	}
+
+	assert.RegexpMatch(t, New("copy", "", func(*NoHelpOpts) error { return nil }).String(), `^Usage: copy <options> <src>\n`)
+
+	type OptionalFirstOpts struct {
+		Name  string   `flag:"[name]"`
+		Files []string `flag:"<files...>"`
+	}
+
+	type VariadicFirstOpts struct {
+		Files []string `flag:"[files...]"`
+		Name  string   `flag:"[name]"`
+	}
+
+	type InvalidTagOpts struct {
+		Files []string `flag:"[files...]" min-args:"x"`
+	}
+
+	type InvalidRangeOpts struct {
+		Files []string `flag:"[files...]" min-args:"3" max-args:"2"`
+	}
+
+	assert.Panic(t, func() {
+		New("test", "", func(*OptionalFirstOpts) error { return nil }).RunArgs(nil)
+	})
+	assert.Panic(t, func() {
+		New("test", "", func(*VariadicFirstOpts) error { return nil }).RunArgs(nil)
+	})
+	assert.Panic(t, func() {
+		New("test", "", func(*InvalidTagOpts) error { return nil }).RunArgs(nil)
+	})
+	assert.Panic(t, func() {
+		New("test", "", func(*InvalidRangeOpts) error { return nil }).RunArgs(nil)
+	})
+}
+
+func TestConfigLayers(t *testing.T) {
+	type DB struct {
+		Host string `flag:"--host <host>" json:"hostname"`
+	}
+
+	type Opts struct {
+		Config  string            `flag:"--config <path>" config:"true" env:"CONFIG"`
+		Name    string            `flag:"--name <name>" default:"tag"`
+		Level   int               `flag:"--level <level>" env:"LEVEL"`
+		Tags    []string          `flag:"--tag <tag>"`
+		Labels  map[string]string `flag:"--label <key=value>"`
+		Verbose bool              `flag:"-v"`
+		Mode    string            `flag:"--mode <mode>" choices:"fast|slow"`
+		DB      DB                `flag-prefix:"db-"`
+	}
+
+	path := t.TempDir() + "/config.json"
+	os.WriteFile(path, []byte(`{"name": "config", "level": 2, "tags": ["a", "b"], "labels": {"x": "1"}, "verbose": true, "db": {"hostname": "db.local"}}`), 0o644)
+
+	var opts *Opts
+	var sources map[string]Source
+	env := map[string]string{}
+	cmd := NewContext("test", "", func(ctx context.Context, o *Opts) error {
+		opts = o
+		sources = ContextSources(ctx)
+		return nil
+	}, Initial(func() *Opts {
+		return &Opts{Name: "initial", Mode: "slow"}
+	}), Modify(func(command CommandMutable) {
+		command.SetGetter(Get(func(name string) (string, bool) {
+			value, ok := env[name]
+			return value, ok
+		}))
+	}))
+
+	err := cmd.RunArgs([]string{})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{Name: "initial", Mode: "slow"})
+	assert.Equal(t, sources["Name"], SourceInitial)
+	assert.Equal(t, sources["Level"], SourceInitial)
+
+	env["LEVEL"] = "3"
+	err = cmd.RunArgs([]string{"--config", path, "--tag", "c"})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, opts, &Opts{
+		Config:  path,
+		Name:    "config",
+		Level:   3,
+		Tags:    []string{"c"},
+		Labels:  map[string]string{"x": "1"},
+		Verbose: true,
+		Mode:    "slow",
+		DB:      DB{Host: "db.local"},
+	})
+	assert.Equal(t, sources, map[string]Source{
+		"Config":  SourceArgs,
+		"Name":    SourceConfig,
+		"Level":   SourceEnv,
+		"Tags":    SourceArgs,
+		"Labels":  SourceConfig,
+		"Verbose": SourceConfig,
+		"Mode":    SourceInitial,
+		"DB.Host": SourceConfig,
+	})
+
+	env["CONFIG"] = t.TempDir() + "/missing.json"
+	err = cmd.RunArgs([]string{})
+
+	assert.NotEqual(t, err, nil)
+
+	os.WriteFile(path, []byte(`{"mode": "medium"}`), 0o644)
+	err = cmd.RunArgs([]string{"--config", path})
+
+	assert.RegexpMatch(t, err.Error(), `^invalid config value for Mode in .*: must be one of "fast", "slow"$`)
+
+	type ChoiceOpts struct {
+		Config string `flag:"--config <path>" config:"true"`
+		Level  int    `flag:"--level <level>" choices:"1|2|3"`
+		Name   string `flag:"--name <name>" json:"-"`
+		Mode   string `flag:"--mode <mode>"`
+	}
+
+	var choiceOpts *ChoiceOpts
+	choiceCmd := New("test", "", func(o *ChoiceOpts) error {
+		choiceOpts = o
+		return nil
+	})
+
+	os.WriteFile(path, []byte(`{"level": 7}`), 0o644)
+	err = choiceCmd.RunArgs([]string{"--config", path})
+
+	assert.RegexpMatch(t, err.Error(), `^invalid config value for Level in .*: must be one of "1", "2", "3"$`)
+
+	os.WriteFile(path, []byte(`{"level": 2, "name": "x", "Name": "y", "MODE": "b", "Mode": "a", "mode": "c"}`), 0o644)
+	err = choiceCmd.RunArgs([]string{"--config", path})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, choiceOpts, &ChoiceOpts{Config: path, Level: 2, Mode: "a"})
+
+	os.WriteFile(path, []byte(`{"mode": "a", "MODE": "b"}`), 0o644)
+	err = choiceCmd.RunArgs([]string{"--config", path})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, choiceOpts.Mode, "b")
+
+	type DefaultOpts struct {
+		Config string `flag:"--config <path>" config:"true" default:"/nonexistent/config.json"`
+		Name   string `flag:"--name <name>" default:"tag"`
+	}
+
+	var defaultSources map[string]Source
+	err = NewContext("test", "", func(ctx context.Context, _ *DefaultOpts) error {
+		defaultSources = ContextSources(ctx)
+		return nil
+	}).RunArgs([]string{})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, defaultSources["Name"], SourceDefault)
+}
@@ -0,0 +1,187 @@
+// Package commandtest runs commands in tests with captured output, exit codes,
+// environment variables, and stdin, and compares help text against golden
+// files.
+package commandtest
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"seahax.com/go/command"
+)
+
+var update = flag.Bool("update-golden", false, "update command help golden files")
+
+// Runnable command. Command pointers (eg. *command.Command[T]) implement this
+// interface.
+type Runnable interface {
+	command.CommandMutable
+	RunArgsAndExit(args []string)
+}
+
+// Command run inputs.
+type Options struct {
+	// Command line arguments, not including the command name.
+	Args []string
+	// Environment variables. The process environment is not used.
+	Env map[string]string
+	// Content read from stdin.
+	Stdin string
+}
+
+// Command run outputs.
+type Result struct {
+	Stdout string
+	Stderr string
+	Code   int
+}
+
+// Run the command with the options, capturing stdout, stderr, and the exit
+// code. The process stdin, stdout, and stderr are replaced while the command
+// runs, so tests using Run must not run in parallel. A copy of the command is
+// run, so the exit function and getter of the command are not replaced.
+func Run(t testing.TB, cmd Runnable, options Options) Result {
+	t.Helper()
+
+	cmd = clone(cmd)
+
+	stdin := tempFile(t, "stdin", options.Stdin)
+	stdout := tempFile(t, "stdout", "")
+	stderr := tempFile(t, "stderr", "")
+	code := 0
+
+	defer swap(&os.Stdin, stdin)()
+	defer swap(&os.Stdout, stdout)()
+	defer swap(&os.Stderr, stderr)()
+
+	cmd.SetExit(func(newCode int) {
+		code = newCode
+	})
+	cmd.SetGetter(command.Get(func(name string) (string, bool) {
+		value, ok := options.Env[name]
+		return value, ok
+	}))
+	cmd.RunArgsAndExit(options.Args)
+
+	return Result{
+		Stdout: readFile(t, stdout),
+		Stderr: readFile(t, stderr),
+		Code:   code,
+	}
+}
+
+// Compare the help text of the command and all of its subcommands against the
+// golden files in the directory. Golden file names are the hyphenated full
+// command names with a ".txt" extension (eg. "tool-subcommand.txt"). Run tests
+// with the -update-golden flag to write the golden files.
+func AssertHelp(t testing.TB, root command.CommandImmutable, dir string) {
+	t.Helper()
+
+	if *update {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Help text written to a terminal is wrapped and styled. Replace stderr
+	// (the default output) so that the golden files are plain text.
+	defer swap(&os.Stderr, tempFile(t, "stderr", ""))()
+
+	walk(root, func(cmd command.CommandImmutable) {
+		t.Helper()
+
+		name := filepath.Join(dir, strings.ReplaceAll(cmd.Fullname(), " ", "-")+".txt")
+		help := cmd.String()
+
+		if *update {
+			if err := os.WriteFile(name, []byte(help), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			return
+		}
+
+		golden, err := os.ReadFile(name)
+
+		if err != nil {
+			t.Errorf("missing golden file for %q (run with -update-golden): %v", cmd.Fullname(), err)
+			return
+		}
+
+		if string(golden) != help {
+			t.Errorf("help for %q does not match %s\nGOT:\n%s\nWANT:\n%s", cmd.Fullname(), name, help, golden)
+		}
+	})
+}
+
+func walk(cmd command.CommandImmutable, fn func(command.CommandImmutable)) {
+	fn(cmd)
+
+	for subcommand := range cmd.Subcommands() {
+		if !subcommand.IsHidden() {
+			walk(subcommand, fn)
+		}
+	}
+}
+
+// Return a copy of the command that the pointer points to.
+func clone(cmd Runnable) Runnable {
+	value := reflect.ValueOf(cmd)
+	copied := reflect.New(value.Type().Elem())
+	copied.Elem().Set(value.Elem())
+	return copied.Interface().(Runnable)
+}
+
+func swap(target **os.File, file *os.File) (restore func()) {
+	original := *target
+	*target = file
+
+	return func() {
+		*target = original
+	}
+}
+
+func tempFile(t testing.TB, name string, content string) *os.File {
+	t.Helper()
+
+	file, err := os.CreateTemp(t.TempDir(), name)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		file.Close()
+	})
+
+	if _, err := io.WriteString(file, content); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	return file
+}
+
+func readFile(t testing.TB, file *os.File) string {
+	t.Helper()
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := io.ReadAll(file)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(content)
+}
@@ -0,0 +1,75 @@
+package commandtest
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"testing"
+
+	"seahax.com/go/assert"
+	"seahax.com/go/command"
+)
+
+type greetOpts struct {
+	Name  string `flag:"[name]" env:"NAME" help:"Name to greet"`
+	Shout bool   `flag:"-s, --shout" help:"Shout the greeting"`
+}
+
+func newTool() Runnable {
+	tool := command.Namespace("tool", "Example tool",
+		command.New("greet", "Greet someone", func(opts *greetOpts) error {
+			if opts.Name == "" {
+				scanner := bufio.NewScanner(os.Stdin)
+				scanner.Scan()
+				opts.Name = scanner.Text()
+			}
+
+			if opts.Shout {
+				return fmt.Errorf("too loud")
+			}
+
+			fmt.Printf("Hello, %s!\n", opts.Name)
+			return nil
+		}),
+	)
+
+	return &tool
+}
+
+func TestRun(t *testing.T) {
+	tool := newTool()
+	result := Run(t, tool, Options{Args: []string{"greet", "world"}})
+
+	assert.Equal(t, result, Result{Stdout: "Hello, world!\n", Code: 0})
+
+	result = Run(t, tool, Options{Args: []string{"greet"}, Env: map[string]string{"NAME": "env"}})
+
+	assert.Equal(t, result, Result{Stdout: "Hello, env!\n", Code: 0})
+
+	result = Run(t, tool, Options{Args: []string{"greet"}, Stdin: "stdin\n"})
+
+	assert.Equal(t, result, Result{Stdout: "Hello, stdin!\n", Code: 0})
+
+	result = Run(t, tool, Options{Args: []string{"greet", "-s", "world"}})
+
+	assert.Equal(t, result, Result{Stderr: "too loud\n", Code: 2})
+
+	result = Run(t, tool, Options{Args: []string{"greet", "--bogus"}})
+
+	assert.Equal(t, result.Code, 1)
+
+	tool.SetGetter(command.Get(func(name string) (string, bool) {
+		return "custom", name == "NAME"
+	}))
+	result = Run(t, tool, Options{Args: []string{"greet"}, Env: map[string]string{"NAME": "env"}})
+
+	assert.Equal(t, result.Stdout, "Hello, env!\n")
+
+	value, _ := tool.Getter().Get("NAME")
+
+	assert.Equal(t, value, "custom")
+}
+
+func TestAssertHelp(t *testing.T) {
+	AssertHelp(t, newTool(), "testdata")
+}
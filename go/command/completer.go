@@ -0,0 +1,14 @@
+package command
+
+// Return dynamic shell completion candidates for a struct field value.
+type Completer interface {
+	// Return completion candidates for the (possibly empty) value prefix.
+	Complete(prefix string) []string
+}
+
+// Return completion candidates for the (possibly empty) value prefix.
+type Complete func(prefix string) []string
+
+func (c Complete) Complete(prefix string) []string {
+	return c(prefix)
+}
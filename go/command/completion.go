@@ -0,0 +1,415 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"seahax.com/go/shorthand"
+)
+
+const completeCommandName = "__complete"
+
+var completionFuncNameReplace = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+type completionOpts struct {
+	Shell string `flag:"<shell>" help:"Shell type (bash, zsh, or fish)" validate:"required,oneof=bash zsh fish"`
+}
+
+type completeOpts struct {
+	Words []string `flag:"<words...>"`
+}
+
+// Add a "completion" subcommand which writes a shell completion script to
+// stdout, and a hidden "__complete" subcommand which the completion script
+// calls to get dynamic completions from field [Completer]s.
+func Completion() Modifier {
+	return Modify(func(command CommandMutable) {
+		command.AddSubcommand(newBuiltin("completion", "Generate a shell completion script", func(_ context.Context, opts *completionOpts) error {
+			return WriteCompletion(os.Stdout, command, opts.Shell)
+		}))
+		command.AddSubcommand(newBuiltin(completeCommandName, "", func(_ context.Context, opts *completeOpts) error {
+			for _, candidate := range completeDynamic(command, opts.Words) {
+				fmt.Fprintln(os.Stdout, candidate)
+			}
+
+			return nil
+		}, Hidden()))
+	})
+}
+
+// Write a bash, zsh, or fish completion script for the command tree. Command
+// and flag names are static. Field values are completed dynamically if the
+// root command has a "__complete" subcommand (see [Completion]).
+func WriteCompletion(w io.Writer, root CommandImmutable, shell string) error {
+	tree := newCompletionTree(root)
+
+	switch shell {
+	case "bash":
+		tree.writeBash(w)
+	case "zsh":
+		tree.writeZsh(w)
+	case "fish":
+		tree.writeFish(w)
+	default:
+		return fmt.Errorf("unsupported shell %q", shell)
+	}
+
+	return nil
+}
+
+type completionTree struct {
+	name     string
+	funcName string
+	dynamic  bool
+	nodes    []completionNode
+}
+
+type completionNode struct {
+	path        string
+	words       []string
+	transitions map[string][]string
+	valueFlags  []string
+	choices     map[string][]string
+}
+
+func newCompletionTree(root CommandImmutable) completionTree {
+	name := shorthand.FirstSeqValue(root.Names())
+	tree := completionTree{
+		name:     name,
+		funcName: completionFuncNameReplace.ReplaceAllString(name, "_"),
+	}
+
+	for subcommand := range root.Subcommands() {
+		if slices.Contains(slices.Collect(subcommand.Names()), completeCommandName) {
+			tree.dynamic = true
+		}
+	}
+
+	tree.add(root, name)
+
+	return tree
+}
+
+func (t *completionTree) add(command CommandImmutable, path string) {
+	node := completionNode{path: path, transitions: map[string][]string{}, choices: map[string][]string{}}
+	children := []CommandImmutable{}
+
+	options, _ := helpFields(command)
+	options = append(options, helpGlobalFields(command)...)
+
+	for _, subcommand := range helpSubcommands(command) {
+		subpath := path + " " + shorthand.FirstSeqValue(subcommand.Names())
+
+		for name := range subcommand.Names() {
+			node.words = append(node.words, name)
+			node.transitions[subpath] = append(node.transitions[subpath], name)
+		}
+
+		children = append(children, subcommand)
+	}
+
+	for _, field := range options {
+		for name := range field.FlagNames() {
+			node.words = append(node.words, formatFlagName(name))
+
+			if field.IsNegatable() && len(name) > 1 {
+				node.words = append(node.words, formatFlagName("no-"+name))
+			}
+
+			name = formatFlagName(name)
+
+			if !field.IsValueless() {
+				node.valueFlags = append(node.valueFlags, name)
+			}
+
+			if choices := field.Choices(); len(choices) > 0 {
+				node.choices[name] = choices
+			}
+		}
+	}
+
+	for flag := range helpFlagActions(command) {
+		node.words = append(node.words, flag)
+	}
+
+	node.words = append(node.words, "--help")
+	t.nodes = append(t.nodes, node)
+
+	for _, child := range children {
+		t.add(child, path+" "+shorthand.FirstSeqValue(child.Names()))
+	}
+}
+
+// Write bash/zsh case arms which update the cmdpath and valueflag variables.
+func (t *completionTree) writeShCaseArms(w io.Writer, indent string, wordExpr string) {
+	for _, node := range t.nodes {
+		for _, subpath := range slices.Sorted(maps.Keys(node.transitions)) {
+			fmt.Fprintf(w, "%s%s) cmdpath=%s ;;\n", indent, node.patterns(node.transitions[subpath], " | "), shellQuote(subpath))
+		}
+
+		if len(node.valueFlags) > 0 {
+			fmt.Fprintf(w, "%s%s) valueflag=\"$cmdpath %s\" ;;\n", indent, node.patterns(node.valueFlags, " | "), wordExpr)
+		}
+	}
+}
+
+// Write bash/zsh case statements which set the candidates array variable.
+func (t *completionTree) writeShCandidates(w io.Writer, variable string) {
+	fmt.Fprintf(w, "\tif [[ -n $valueflag ]]; then\n\t\tcase \"$valueflag\" in\n")
+
+	for _, node := range t.nodes {
+		for _, flags := range node.choiceFlags() {
+			fmt.Fprintf(w, "\t\t%s) %s=(%s) ;;\n", node.patterns(flags, " | "), variable, shellQuoteJoin(node.choices[flags[0]]))
+		}
+	}
+
+	fmt.Fprintf(w, "\t\tesac\n\telse\n\t\tcase \"$cmdpath\" in\n")
+
+	for _, node := range t.nodes {
+		fmt.Fprintf(w, "\t\t%s) %s=(%s) ;;\n", shellQuote(node.path), variable, shellQuoteJoin(node.words))
+	}
+
+	fmt.Fprintf(w, "\t\tesac\n\tfi\n\n")
+}
+
+func (t *completionTree) writeBash(w io.Writer) {
+	fmt.Fprintf(w, "# bash completion for %s\n\n", t.name)
+	fmt.Fprintf(w, "_%s_completion() {\n", t.funcName)
+	fmt.Fprintf(w, "\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	fmt.Fprintf(w, "\tlocal cmdpath=%s valueflag='' i\n", shellQuote(t.name))
+	fmt.Fprintf(w, "\tlocal -a words\n\n")
+	fmt.Fprintf(w, "\tfor ((i = 1; i < COMP_CWORD; i++)); do\n")
+	fmt.Fprintf(w, "\t\tif [[ -n $valueflag ]]; then\n\t\t\tvalueflag=''\n\t\t\tcontinue\n\t\tfi\n\n")
+	fmt.Fprintf(w, "\t\tcase \"$cmdpath ${COMP_WORDS[i]}\" in\n")
+	t.writeShCaseArms(w, "\t\t", "${COMP_WORDS[i]}")
+	fmt.Fprintf(w, "\t\tesac\n\tdone\n\n")
+	t.writeShCandidates(w, "words")
+
+	if t.dynamic {
+		fmt.Fprintf(w, "\twords+=($(%s %s -- \"${COMP_WORDS[@]:1:COMP_CWORD}\" 2>/dev/null))\n", shellQuote(t.name), completeCommandName)
+	}
+
+	fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W \"${words[*]}\" -- \"$cur\"))\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "complete -F _%s_completion %s\n", t.funcName, shellQuote(t.name))
+}
+
+func (t *completionTree) writeZsh(w io.Writer) {
+	fmt.Fprintf(w, "#compdef %s\n\n", t.name)
+	fmt.Fprintf(w, "_%s_completion() {\n", t.funcName)
+	fmt.Fprintf(w, "\tlocal cmdpath=%s valueflag='' i\n", shellQuote(t.name))
+	fmt.Fprintf(w, "\tlocal -a candidates\n\n")
+	fmt.Fprintf(w, "\tfor ((i = 2; i < CURRENT; i++)); do\n")
+	fmt.Fprintf(w, "\t\tif [[ -n $valueflag ]]; then\n\t\t\tvalueflag=''\n\t\t\tcontinue\n\t\tfi\n\n")
+	fmt.Fprintf(w, "\t\tcase \"$cmdpath ${words[i]}\" in\n")
+	t.writeShCaseArms(w, "\t\t", "${words[i]}")
+	fmt.Fprintf(w, "\t\tesac\n\tdone\n\n")
+	t.writeShCandidates(w, "candidates")
+
+	if t.dynamic {
+		fmt.Fprintf(w, "\tcandidates+=(${(f)\"$(%s %s -- \"${(@)words[2,CURRENT]}\" 2>/dev/null)\"})\n", shellQuote(t.name), completeCommandName)
+	}
+
+	fmt.Fprintf(w, "\tcompadd -- \"${candidates[@]}\"\n")
+	fmt.Fprintf(w, "}\n\n")
+	fmt.Fprintf(w, "compdef _%s_completion %s\n", t.funcName, shellQuote(t.name))
+}
+
+func (t *completionTree) writeFish(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion for %s\n\n", t.name)
+	fmt.Fprintf(w, "function __%s_completion\n", t.funcName)
+	fmt.Fprintf(w, "\tset -l tokens (commandline -opc)\n")
+	fmt.Fprintf(w, "\tset -l current (commandline -ct)\n")
+	fmt.Fprintf(w, "\tset -l cmdpath %s\n", shellQuote(t.name))
+	fmt.Fprintf(w, "\tset -l valueflag ''\n\n")
+	fmt.Fprintf(w, "\tfor word in $tokens[2..-1]\n")
+	fmt.Fprintf(w, "\t\tif test -n \"$valueflag\"\n\t\t\tset valueflag ''\n\t\t\tcontinue\n\t\tend\n\n")
+	fmt.Fprintf(w, "\t\tswitch \"$cmdpath $word\"\n")
+
+	for _, node := range t.nodes {
+		for _, subpath := range slices.Sorted(maps.Keys(node.transitions)) {
+			fmt.Fprintf(w, "\t\t\tcase %s\n\t\t\t\tset cmdpath %s\n", node.patterns(node.transitions[subpath], " "), shellQuote(subpath))
+		}
+
+		if len(node.valueFlags) > 0 {
+			fmt.Fprintf(w, "\t\t\tcase %s\n\t\t\t\tset valueflag \"$cmdpath $word\"\n", node.patterns(node.valueFlags, " "))
+		}
+	}
+
+	fmt.Fprintf(w, "\t\tend\n\tend\n\n")
+	fmt.Fprintf(w, "\tif test -n \"$valueflag\"\n\t\tswitch $valueflag\n")
+
+	for _, node := range t.nodes {
+		for _, flags := range node.choiceFlags() {
+			fmt.Fprintf(w, "\t\t\tcase %s\n\t\t\t\tprintf '%%s\\n' %s\n", node.patterns(flags, " "), shellQuoteJoin(node.choices[flags[0]]))
+		}
+	}
+
+	fmt.Fprintf(w, "\t\tend\n\telse\n\t\tswitch $cmdpath\n")
+
+	for _, node := range t.nodes {
+		fmt.Fprintf(w, "\t\t\tcase %s\n\t\t\t\tprintf '%%s\\n' %s\n", shellQuote(node.path), shellQuoteJoin(node.words))
+	}
+
+	fmt.Fprintf(w, "\t\tend\n\tend\n")
+
+	if t.dynamic {
+		fmt.Fprintf(w, "\n\t%s %s -- $tokens[2..-1] \"$current\" 2>/dev/null\n", shellQuote(t.name), completeCommandName)
+	}
+
+	fmt.Fprintf(w, "end\n\n")
+	fmt.Fprintf(w, "complete -c %s -f -a '(__%s_completion)'\n", shellQuote(t.name), t.funcName)
+}
+
+// Return quoted "<path> <word>" patterns joined by the separator.
+func (n completionNode) patterns(words []string, separator string) string {
+	return strings.Join(shorthand.Select(words, func(_ int, word string) string {
+		return shellQuote(n.path + " " + word)
+	}), separator)
+}
+
+// Return value flags which have choices, grouped by field.
+func (n completionNode) choiceFlags() [][]string {
+	groups := [][]string{}
+
+	for _, flag := range n.valueFlags {
+		choices, ok := n.choices[flag]
+
+		if !ok {
+			continue
+		}
+
+		if i := len(groups) - 1; i >= 0 && slices.Equal(n.choices[groups[i][0]], choices) {
+			groups[i] = append(groups[i], flag)
+		} else {
+			groups = append(groups, []string{flag})
+		}
+	}
+
+	return groups
+}
+
+// Return dynamic completion candidates for the last word, resolving the
+// preceding words to a subcommand and a field.
+func completeDynamic(command CommandImmutable, words []string) []string {
+	if len(words) == 0 {
+		return nil
+	}
+
+	prefix := words[len(words)-1]
+	words = words[:len(words)-1]
+	positional := 0
+	terminated := false
+	var valueField *Field
+
+	for _, word := range words {
+		if valueField != nil {
+			valueField = nil
+			continue
+		}
+
+		if !terminated && word == "--" {
+			terminated = true
+			continue
+		}
+
+		if !terminated && strings.HasPrefix(word, "-") && word != "-" {
+			field, ok := lookupFlagField(word, append(persistentTypes(command), command.Type())...)
+
+			if ok && !strings.Contains(word, "=") && !field.IsValueless() {
+				valueField = &field
+			}
+
+			continue
+		}
+
+		if positional == 0 && !terminated {
+			if subcommand, ok := lookupSubcommand(command, word); ok {
+				command = subcommand
+				continue
+			}
+		}
+
+		positional++
+	}
+
+	if valueField == nil {
+		if !terminated && strings.HasPrefix(prefix, "-") {
+			return nil
+		}
+
+		fields := slices.Collect(shorthand.FilterSeq(FieldIterator(command.Type()), func(_ int, field Field) bool {
+			return !field.IsNamedFlag()
+		}))
+
+		if len(fields) == 0 {
+			return nil
+		}
+
+		if positional < len(fields) {
+			valueField = &fields[positional]
+		} else if last := fields[len(fields)-1]; last.IsSlice() {
+			if _, maxArgs := last.ArgsRange(); maxArgs > 0 && positional-len(fields)+1 >= maxArgs {
+				return nil
+			}
+
+			valueField = &last
+		} else {
+			return nil
+		}
+	}
+
+	completer := command.Completer(valueField.Name())
+
+	if completer == nil {
+		if valueField.IsNamedFlag() {
+			// Named flag choices are included in the static script.
+			return nil
+		}
+
+		return valueField.Choices()
+	}
+
+	return completer.Complete(prefix)
+}
+
+func lookupSubcommand(command CommandImmutable, name string) (CommandImmutable, bool) {
+	for subcommand := range command.Subcommands() {
+		if slices.Contains(slices.Collect(subcommand.Names()), name) {
+			return subcommand, true
+		}
+	}
+
+	return nil, false
+}
+
+func lookupFlagField(arg string, structTypes ...reflect.Type) (Field, bool) {
+	name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+
+	for _, structType := range slices.Backward(structTypes) {
+		for field := range FieldIterator(structType) {
+			if slices.Contains(slices.Collect(field.FlagNames()), name) {
+				return field, true
+			}
+		}
+	}
+
+	return Field{}, false
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func shellQuoteJoin(values []string) string {
+	return strings.Join(shorthand.Select(values, func(_ int, value string) string {
+		return shellQuote(value)
+	}), " ")
+}
@@ -0,0 +1,156 @@
+package command
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"seahax.com/go/shorthand"
+)
+
+// The layer which provided a parsed field value.
+type Source string
+
+const (
+	// The initial value (see [Initial]), or the zero value.
+	SourceInitial Source = "initial"
+	// The "default" tag.
+	SourceDefault Source = "default"
+	// The JSON config file (see [Field.IsConfig]).
+	SourceConfig Source = "config"
+	// An environment variable (the "env" tag).
+	SourceEnv Source = "env"
+	// A command line argument.
+	SourceArgs Source = "args"
+	// An interactive prompt (the "prompt" tag).
+	SourcePrompt Source = "prompt"
+)
+
+var sourcesContextKey = shorthand.NewContextKey[map[string]Source](nil)
+
+// Set the initial value of the command's option struct T. Default tags are
+// only applied to fields which are zero values in the initial value. The
+// factory is called for every run, so it should return a new value each time.
+func Initial[T any](initial func() *T) Modifier {
+	return Modify(func(command CommandMutable) {
+		command.SetInitial(func() any {
+			return initial()
+		})
+	})
+}
+
+// Get the [Source] of each option value from an action context, keyed by
+// field name ([Field.Name]). Returns nil if the context does not belong to a
+// command action.
+func ContextSources(ctx context.Context) map[string]Source {
+	return sourcesContextKey.Value(ctx)
+}
+
+// Read a JSON config file. A leading "~/" in the path is replaced with the
+// user's home directory.
+func readConfig(path string) (map[string]json.RawMessage, error) {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		home, err := os.UserHomeDir()
+
+		if err != nil {
+			return nil, err
+		}
+
+		path = filepath.Join(home, rest)
+	}
+
+	data, err := os.ReadFile(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]json.RawMessage{}
+
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Look up the JSON config value of a field. Fields of nested structs are
+// looked up in nested objects. Keys match struct field names
+// (case-insensitive), or "json" tag names. Fields with a "json" tag of "-" are
+// excluded. Unknown keys are ignored.
+func configLookup(config map[string]json.RawMessage, field Field) (json.RawMessage, bool) {
+	names := strings.Split(field.Name(), ".")
+	tag := field.structField.Tag.Get("json")
+
+	if tag == "-" {
+		return nil, false
+	}
+
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		names[len(names)-1] = name
+	}
+
+	for i, name := range names {
+		raw, ok := configKey(config, name)
+
+		if !ok {
+			return nil, false
+		}
+
+		if i == len(names)-1 {
+			return raw, true
+		}
+
+		config = map[string]json.RawMessage{}
+
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// Get the value of a config key. An exact match is preferred. Otherwise, the
+// first case-insensitive match (in sorted key order) is used.
+func configKey(config map[string]json.RawMessage, name string) (json.RawMessage, bool) {
+	if raw, ok := config[name]; ok {
+		return raw, true
+	}
+
+	for _, key := range slices.Sorted(maps.Keys(config)) {
+		if strings.EqualFold(key, name) {
+			return config[key], true
+		}
+	}
+
+	return nil, false
+}
+
+// Get the text of a JSON string, number, or boolean value, so that it can be
+// decoded like an argument value.
+func configText(raw json.RawMessage) (string, bool) {
+	var value any
+
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", false
+	}
+
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case float64:
+		return string(bytes.TrimSpace(raw)), true
+	case bool:
+		return strconv.FormatBool(value), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,15 @@
+package command
+
+import (
+	"context"
+
+	"seahax.com/go/shorthand"
+)
+
+var commandContextKey = shorthand.NewContextKey[CommandImmutable](nil)
+
+// Get the running command from an action context, or nil if the context does
+// not belong to a command action.
+func ContextCommand(ctx context.Context) CommandImmutable {
+	return commandContextKey.Value(ctx)
+}
@@ -1,12 +1,38 @@
 package command
 
 import (
+	"io"
+	"os"
 	"reflect"
+	"strings"
 
 	"seahax.com/go/shorthand"
 )
 
-// Default decoder.
+// Default decoder. Values of type []byte are read from a file if the value is
+// "@path", or stdin if the value is "-". Values of type [io.Reader] are
+// decoded as a [*FileSource] if the value is "@path" or "-", or else read from
+// the value string.
 var DecoderDefault Decoder = Decode(func(value string, targetType reflect.Type) (any, error) {
+	switch targetType {
+	case reflect.TypeFor[[]byte]():
+		switch {
+		case value == "-":
+			return io.ReadAll(os.Stdin)
+		case strings.HasPrefix(value, "@"):
+			return os.ReadFile(value[1:])
+		default:
+			return []byte(value), nil
+		}
+	case reflect.TypeFor[io.Reader]():
+		if isFileSourceValue(value) {
+			source := &FileSource{}
+			err := source.UnmarshalText([]byte(value))
+			return source, err
+		}
+
+		return strings.NewReader(value), nil
+	}
+
 	return shorthand.Decode(value, targetType)
 })
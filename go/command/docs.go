@@ -0,0 +1,291 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"seahax.com/go/shorthand"
+)
+
+var docsUsagePrefix = regexp.MustCompile(`^Usage:\s*`)
+var docsMarkdownEscape = strings.NewReplacer(
+	`\`, `\\`,
+	"`", "\\`",
+	`*`, `\*`,
+	`_`, `\_`,
+	`<`, `\<`,
+	`>`, `\>`,
+	`[`, `\[`,
+	`]`, `\]`,
+)
+var docsMarkdownAnchor = regexp.MustCompile(`[^a-z0-9 _-]`)
+var docsRoffEscape = strings.NewReplacer(
+	`\`, `\e`,
+	`-`, `\-`,
+)
+
+// Write a roff man page (section 1) for a single command.
+func WriteMan(w io.Writer, command CommandImmutable) error {
+	b := &strings.Builder{}
+	options, arguments := helpFields(command)
+	subcommands := helpSubcommands(command)
+	name := docsManName(command)
+
+	fmt.Fprintf(b, ".TH %s \"1\"\n", docsRoffQuote(strings.ToUpper(name)))
+	fmt.Fprintf(b, ".SH NAME\n")
+
+	if summary := command.Summary(); summary != "" {
+		fmt.Fprintf(b, "%s \\- %s\n", docsRoff(name), docsRoff(summary))
+	} else {
+		fmt.Fprintf(b, "%s\n", docsRoff(name))
+	}
+
+	fmt.Fprintf(b, ".SH SYNOPSIS\n.nf\n")
+
+	for _, usage := range helpUsage(command) {
+		fmt.Fprintf(b, "%s\n", docsRoff(docsUsagePrefix.ReplaceAllString(usage, "")))
+	}
+
+	fmt.Fprintf(b, ".fi\n")
+
+	if prologue := shorthand.Filter(slices.Collect(command.Prologue()), docsNotEmpty); len(prologue) > 0 {
+		fmt.Fprintf(b, ".SH DESCRIPTION\n")
+		docsManParagraphs(b, prologue)
+	}
+
+	if len(options) > 0 {
+		fmt.Fprintf(b, ".SH OPTIONS\n")
+
+		for _, field := range options {
+			docsManItem(b, helpFieldKey(command, field), helpFieldText(field))
+		}
+	}
+
+	if globals := helpGlobalFields(command); len(globals) > 0 {
+		fmt.Fprintf(b, ".SH GLOBAL OPTIONS\n")
+
+		for _, field := range globals {
+			docsManItem(b, helpFieldKey(command, field), helpFieldText(field))
+		}
+	}
+
+	if len(arguments) > 0 {
+		fmt.Fprintf(b, ".SH ARGUMENTS\n")
+
+		for _, field := range arguments {
+			docsManItem(b, helpFieldKey(command, field), helpFieldText(field))
+		}
+	}
+
+	if len(subcommands) > 0 {
+		fmt.Fprintf(b, ".SH COMMANDS\n")
+
+		for _, subcommand := range subcommands {
+			docsManItem(b, docsNames(subcommand), subcommand.Summary())
+		}
+	}
+
+	if epilogue := shorthand.Filter(slices.Collect(command.Epilogue()), docsNotEmpty); len(epilogue) > 0 {
+		fmt.Fprintf(b, ".SH NOTES\n")
+		docsManParagraphs(b, epilogue)
+	}
+
+	seeAlso := []string{}
+
+	if parent := command.Parent(); parent != nil {
+		seeAlso = append(seeAlso, fmt.Sprintf("\\fB%s\\fR(1)", docsRoff(docsManName(parent))))
+	}
+
+	for _, subcommand := range subcommands {
+		seeAlso = append(seeAlso, fmt.Sprintf("\\fB%s\\fR(1)", docsRoff(docsManName(subcommand))))
+	}
+
+	if len(seeAlso) > 0 {
+		fmt.Fprintf(b, ".SH SEE ALSO\n%s\n", strings.Join(seeAlso, ", "))
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Write a man page for every command in the tree to files in the directory.
+// File names are the hyphenated full command names with a ".1" extension (eg.
+// "tool-subcommand.1").
+func WriteManTree(dir string, root CommandImmutable) error {
+	for command := range docsTree(root) {
+		file, err := os.Create(filepath.Join(dir, docsManName(command)+".1"))
+
+		if err != nil {
+			return err
+		}
+
+		err = WriteMan(file, command)
+
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write a Markdown reference for every command in the tree.
+func WriteMarkdown(w io.Writer, root CommandImmutable) error {
+	b := &strings.Builder{}
+
+	for command := range docsTree(root) {
+		options, arguments := helpFields(command)
+		subcommands := helpSubcommands(command)
+
+		if b.Len() > 0 {
+			b.WriteString("\n")
+		}
+
+		if command.Parent() == nil {
+			fmt.Fprintf(b, "# %s\n", command.Fullname())
+		} else {
+			fmt.Fprintf(b, "## %s\n", command.Fullname())
+		}
+
+		if summary := command.Summary(); summary != "" {
+			fmt.Fprintf(b, "\n%s\n", docsMarkdownEscape.Replace(summary))
+		}
+
+		fmt.Fprintf(b, "\n```\n")
+
+		for _, usage := range helpUsage(command) {
+			fmt.Fprintf(b, "%s\n", docsUsagePrefix.ReplaceAllString(usage, ""))
+		}
+
+		fmt.Fprintf(b, "```\n")
+
+		for prologue := range command.Prologue() {
+			if prologue != "" {
+				fmt.Fprintf(b, "\n%s\n", docsMarkdownEscape.Replace(prologue))
+			}
+		}
+
+		if len(options) > 0 {
+			fmt.Fprintf(b, "\n### Options\n\n")
+
+			for _, field := range options {
+				docsMarkdownItem(b, fmt.Sprintf("`%s`", helpFieldKey(command, field)), helpFieldText(field))
+			}
+		}
+
+		if globals := helpGlobalFields(command); len(globals) > 0 {
+			fmt.Fprintf(b, "\n### Global options\n\n")
+
+			for _, field := range globals {
+				docsMarkdownItem(b, fmt.Sprintf("`%s`", helpFieldKey(command, field)), helpFieldText(field))
+			}
+		}
+
+		if len(arguments) > 0 {
+			fmt.Fprintf(b, "\n### Arguments\n\n")
+
+			for _, field := range arguments {
+				docsMarkdownItem(b, fmt.Sprintf("`%s`", helpFieldKey(command, field)), helpFieldText(field))
+			}
+		}
+
+		if len(subcommands) > 0 {
+			fmt.Fprintf(b, "\n### Commands\n\n")
+
+			for _, subcommand := range subcommands {
+				anchor := docsMarkdownAnchor.ReplaceAllString(strings.ToLower(subcommand.Fullname()), "")
+				anchor = strings.ReplaceAll(anchor, " ", "-")
+				docsMarkdownItem(b, fmt.Sprintf("[`%s`](#%s)", docsNames(subcommand), anchor), subcommand.Summary())
+			}
+		}
+
+		for epilogue := range command.Epilogue() {
+			if epilogue != "" {
+				fmt.Fprintf(b, "\n%s\n", docsMarkdownEscape.Replace(epilogue))
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Return a Seq that yields the command and all of its (visible) descendant
+// commands, depth first.
+func docsTree(root CommandImmutable) iter.Seq[CommandImmutable] {
+	return func(yield func(CommandImmutable) bool) {
+		docsTreeYield(root, yield)
+	}
+}
+
+func docsTreeYield(command CommandImmutable, yield func(CommandImmutable) bool) bool {
+	if !yield(command) {
+		return false
+	}
+
+	for _, subcommand := range helpSubcommands(command) {
+		if !docsTreeYield(subcommand, yield) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func docsManName(command CommandImmutable) string {
+	return strings.ReplaceAll(command.Fullname(), " ", "-")
+}
+
+func docsNames(command CommandImmutable) string {
+	return strings.Join(slices.Collect(command.Names()), ", ")
+}
+
+func docsRoff(s string) string {
+	lines := strings.Split(docsRoffEscape.Replace(s), "\n")
+
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Quote a roff macro argument. Backslashes are escaped, and double quotes are
+// doubled.
+func docsRoffQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\e`, `"`, `""`, "\n", " ").Replace(s) + `"`
+}
+
+func docsManParagraphs(b *strings.Builder, paragraphs []string) {
+	for i, paragraph := range paragraphs {
+		if i > 0 {
+			fmt.Fprintf(b, ".PP\n")
+		}
+
+		fmt.Fprintf(b, "%s\n", docsRoff(paragraph))
+	}
+}
+
+func docsManItem(b *strings.Builder, key string, s string) {
+	fmt.Fprintf(b, ".TP\n\\fB%s\\fR\n%s\n", docsRoff(key), docsRoff(s))
+}
+
+func docsMarkdownItem(b *strings.Builder, key string, s string) {
+	fmt.Fprintf(b, "- %s: %s\n", key, docsMarkdownEscape.Replace(strings.ReplaceAll(s, "\n", " ")))
+}
+
+func docsNotEmpty(_ int, s string) bool {
+	return s != ""
+}
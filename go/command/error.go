@@ -1,10 +1,20 @@
 package command
 
+import (
+	"errors"
+	"flag"
+	"fmt"
+)
+
 // Error returned by [Command.Run] and [Command.RunArgs].
 type Error struct {
 	error
 	command        CommandImmutable
 	IsParseFailure bool
+
+	// Similar valid values (eg. subcommand or flag names) when the error is
+	// caused by an unknown value.
+	Suggestions []string
 }
 
 // Create a new [Error].
@@ -16,6 +26,56 @@ func (e *Error) Command() CommandImmutable {
 	return e.command
 }
 
+// Get the error message, including suggestions if there are any. If there is
+// no wrapped error, the message is built from the exit code.
+func (e *Error) Error() string {
+	message := fmt.Sprintf("exit status %d", e.ExitCode())
+
+	if e.error != nil {
+		message = e.error.Error()
+	}
+
+	if len(e.Suggestions) > 0 {
+		return fmt.Sprintf("%s (%s)", message, suggestFormat(e.Suggestions))
+	}
+
+	return message
+}
+
+// Get the process exit code. The code of a wrapped [ExitError] is used if it
+// is non-zero. Otherwise, help requests exit with [ExitCodeSuccess], parse
+// failures with [ExitCodeParseFailure], and other errors with
+// [ExitCodeActionFailure].
+func (e *Error) ExitCode() int {
+	if exitErr := (*ExitError)(nil); errors.As(e.error, &exitErr) && exitErr.Code != 0 {
+		return exitErr.Code
+	}
+
+	if pluginErr := (*pluginExitError)(nil); errors.As(e.error, &pluginErr) {
+		return pluginErr.ExitCode()
+	}
+
+	if errors.Is(e.error, flag.ErrHelp) {
+		return ExitCodeSuccess
+	}
+
+	if e.IsParseFailure {
+		return ExitCodeParseFailure
+	}
+
+	return ExitCodeActionFailure
+}
+
+// Get the hint text of a wrapped [ExitError], or an empty string if there is
+// no hint.
+func (e *Error) Hint() string {
+	if exitErr := (*ExitError)(nil); errors.As(e.error, &exitErr) {
+		return exitErr.Hint
+	}
+
+	return ""
+}
+
 // Unwrap the error to get the original error (ie. the cause).
 func (e *Error) Unwrap() error {
 	return e.error
@@ -0,0 +1,52 @@
+package command
+
+import "fmt"
+
+const (
+	// Exit code for help requests and successful runs.
+	ExitCodeSuccess = 0
+	// Default exit code for parse failures (eg. invalid arguments).
+	ExitCodeParseFailure = 1
+	// Default exit code for action errors.
+	ExitCodeActionFailure = 2
+)
+
+// Error with a process exit code and optional hint text, which actions can
+// return (optionally wrapped) to control the [Command.RunArgsAndExit] exit
+// code. A zero code keeps the default exit code.
+type ExitError struct {
+	error
+	Code int
+	Hint string
+}
+
+// Create a new [ExitError]. The error may be nil if only the code and hint are
+// needed, in which case the message is built from the code.
+func NewExitError(code int, err error, hint string) *ExitError {
+	return &ExitError{error: err, Code: code, Hint: hint}
+}
+
+// Create a new [ExitError] with a formatted message.
+func ExitErrorf(code int, format string, args ...any) *ExitError {
+	return &ExitError{error: fmt.Errorf(format, args...), Code: code}
+}
+
+// Get the error message, or a message built from the code if there is no
+// error.
+func (e *ExitError) Error() string {
+	if e.error == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+
+	return e.error.Error()
+}
+
+// Get the process exit code.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+// Unwrap the error to get the original error (ie. the cause).
+func (e *ExitError) Unwrap() error {
+	return e.error
+}
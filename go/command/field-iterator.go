@@ -3,26 +3,56 @@ package command
 import (
 	"iter"
 	"reflect"
+	"slices"
+
+	"seahax.com/go/shorthand"
 )
 
 // Return a new Seq that yields each visable and exported struct field that is
-// tagged with the "flag" tag.
+// tagged with the "flag" tag. Fields of nested struct fields tagged with the
+// "flag-prefix" tag are also yielded, with the nested struct field's flag
+// prefix, "env-prefix" tag, and "help" tag (section title) applied.
 func FieldIterator(structType reflect.Type) iter.Seq[Field] {
 	return func(yield func(Field) bool) {
-		for _, structField := range reflect.VisibleFields(structType) {
-			if !structField.IsExported() {
-				continue
-			}
+		fieldIteratorYield(structType, structType, Field{}, nil, yield)
+	}
+}
 
-			field := NewField(structType, structField)
+func fieldIteratorYield(structPtrType reflect.Type, structType reflect.Type, parent Field, index []int, yield func(Field) bool) bool {
+	for _, structField := range reflect.VisibleFields(structType) {
+		if !structField.IsExported() {
+			continue
+		}
+
+		structField.Index = append(slices.Clone(index), structField.Index...)
 
-			if field.Flag() == "" {
-				continue
+		if prefix, ok := structField.Tag.Lookup(tagFlagPrefix); ok && !structField.Anonymous && structField.Type.Kind() == reflect.Struct {
+			nested := Field{
+				scope:      parent.scope + structField.Name + ".",
+				flagPrefix: parent.flagPrefix + prefix,
+				envPrefix:  parent.envPrefix + structField.Tag.Get(tagEnvPrefix),
+				section:    shorthand.Coalesce(structField.Tag.Get(tagHelp), parent.section, structField.Name),
 			}
 
-			if !yield(field) {
-				return
+			if !fieldIteratorYield(structPtrType, structField.Type, nested, structField.Index, yield) {
+				return false
 			}
+
+			continue
+		}
+
+		field := parent
+		field.structField = structField
+		field.structPtrType = structPtrType
+
+		if field.Flag() == "" {
+			continue
+		}
+
+		if !yield(field) {
+			return false
 		}
 	}
+
+	return true
 }
@@ -5,12 +5,41 @@ import (
 	"iter"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"seahax.com/go/shorthand"
 )
 
 const (
-	tagFlag string = "flag"
-	tagHelp string = "help"
+	tagFlag    string = "flag"
+	tagHelp    string = "help"
+	tagEnv     string = "env"
+	tagDefault string = "default"
+	tagChoices string = "choices"
+
+	tagCount     string = "count"
+	tagNegatable string = "negatable"
+
+	tagExclusive   string = "exclusive"
+	tagTogether    string = "together"
+	tagRequiredAny string = "required-any"
+
+	tagHidden     string = "hidden"
+	tagDeprecated string = "deprecated"
+	tagForward    string = "forward"
+
+	tagValidate string = "validate"
+	tagPrompt   string = "prompt"
+	tagSecret   string = "secret"
+
+	tagMinArgs string = "min-args"
+	tagMaxArgs string = "max-args"
+
+	tagConfig string = "config"
+
+	tagFlagPrefix string = "flag-prefix"
+	tagEnvPrefix  string = "env-prefix"
 )
 
 var fieldFlagSplit = regexp.MustCompile(`[ ,|]`)
@@ -20,6 +49,15 @@ var fieldFlagMatch = regexp.MustCompile(`-+([a-zA-Z0-9]+(?:-+[a-zA-Z0-9]+)*)`)
 type Field struct {
 	structField   reflect.StructField
 	structPtrType reflect.Type
+
+	// Nested struct field name path (eg. "DB.").
+	scope string
+	// Nested struct long flag name prefix (eg. "db-").
+	flagPrefix string
+	// Nested struct environment variable name prefix (eg. "DB_").
+	envPrefix string
+	// Nested struct help section title.
+	section string
 }
 
 // Create a new [Field].
@@ -27,9 +65,51 @@ func NewField(structPtrType reflect.Type, field reflect.StructField) Field {
 	return Field{structField: field, structPtrType: structPtrType}
 }
 
-// Get the flag usage (the value of the "flag" tag).
+// Get the struct field name. Fields of nested structs are qualified by the
+// nested struct field names (eg. "DB.Host").
+func (f Field) Name() string {
+	return f.scope + f.structField.Name
+}
+
+// Get the flag usage (the value of the "flag" tag). Fields of nested structs
+// with a flag prefix have their long names prefixed, and their short names
+// removed.
 func (f Field) Flag() string {
-	return f.structField.Tag.Get(tagFlag)
+	flag := f.structField.Tag.Get(tagFlag)
+
+	if f.flagPrefix == "" || !strings.HasPrefix(flag, "-") {
+		return flag
+	}
+
+	names := []string{}
+	rest := ""
+	offset := 0
+
+	for _, part := range fieldFlagSplit.Split(flag, -1) {
+		if part != "" && !strings.HasPrefix(part, "-") {
+			// Keep the value placeholder (eg. "<value>") verbatim.
+			rest = " " + flag[offset:]
+			break
+		}
+
+		if match := fieldFlagMatch.FindStringSubmatch(part); match != nil && len(match[1]) > 1 {
+			names = append(names, "--"+f.flagPrefix+match[1])
+		}
+
+		offset += len(part) + 1
+	}
+
+	if len(names) == 0 {
+		panic(fmt.Sprintf("flag %q of prefixed field %s has no long name", flag, f.Name()))
+	}
+
+	return strings.Join(names, ", ") + rest
+}
+
+// Get the help section title of the nested struct containing the field, or an
+// empty string for fields of the command struct.
+func (f Field) Section() string {
+	return f.section
 }
 
 // True if the flag usage ([Field.Flag]) starts with a hyphen, indicating a
@@ -58,25 +138,250 @@ func (f Field) FlagNames() iter.Seq[string] {
 	}
 }
 
+// True if the field is a required positional argument (the flag usage is
+// enclosed in angle brackets, eg. "<arg>"). Optional positional arguments are
+// enclosed in square brackets (eg. "[arg]").
+func (f Field) IsRequired() bool {
+	return strings.HasPrefix(f.Flag(), "<")
+}
+
+// Get the minimum and maximum number of values of a variadic (slice)
+// positional argument (the "min-args" and "max-args" tags). The minimum
+// defaults to one if the argument is required ([Field.IsRequired]), and zero
+// otherwise. A maximum of zero is unbounded. Panics if a tag is not a
+// non-negative integer, or if the minimum is greater than the maximum.
+func (f Field) ArgsRange() (minArgs int, maxArgs int) {
+	if f.IsRequired() {
+		minArgs = 1
+	}
+
+	minArgs = f.argsTag(tagMinArgs, minArgs)
+	maxArgs = f.argsTag(tagMaxArgs, maxArgs)
+
+	if maxArgs > 0 && minArgs > maxArgs {
+		panic(fmt.Sprintf("%s tag %d is greater than %s tag %d for %s", tagMinArgs, minArgs, tagMaxArgs, maxArgs, f.Name()))
+	}
+
+	return minArgs, maxArgs
+}
+
 // Get the flag help text (the value of the "help" tag).
 func (f Field) Help() string {
 	return f.structField.Tag.Get(tagHelp)
 }
 
-// Get the element type of the struct field. If the struct field is a slice,
-// return the slice element type. This is the type used to decode individual
+// True if the field is omitted from help text and completions (the "hidden"
+// tag is "true").
+func (f Field) IsHidden() bool {
+	return f.structField.Tag.Get(tagHidden) == "true"
+}
+
+// Get the deprecation message (the value of the "deprecated" tag). Deprecated
+// flags are omitted from help text and completions, and print a warning when
+// used. If the field forwards to another flag ([Field.Forward]), the message
+// defaults to suggesting the other flag.
+func (f Field) Deprecated() string {
+	if message := f.structField.Tag.Get(tagDeprecated); message != "" {
+		return message
+	}
+
+	if forward := f.Forward(); forward != "" {
+		return fmt.Sprintf("use %s instead", forward)
+	}
+
+	return ""
+}
+
+// Get the replacement flag (the value of the "forward" tag, eg. "--new")
+// which receives the values of a deprecated flag.
+func (f Field) Forward() string {
+	return f.structField.Tag.Get(tagForward)
+}
+
+// Get the environment variable name (the value of the "env" tag), without any
+// command prefix. If the struct field is a slice, the environment variable
+// value is split on commas.
+func (f Field) Env() string {
+	if env := f.structField.Tag.Get(tagEnv); env != "" {
+		return f.envPrefix + env
+	}
+
+	return ""
+}
+
+// Get the default value (the value of the "default" tag). If the struct field
+// is a slice, the default value is split on commas.
+func (f Field) Default() string {
+	return f.structField.Tag.Get(tagDefault)
+}
+
+// Get the allowed values (the pipe separated values of the "choices" tag).
+func (f Field) Choices() []string {
+	choices := []string{}
+
+	for choice := range strings.SplitSeq(f.structField.Tag.Get(tagChoices), "|") {
+		if choice = strings.TrimSpace(choice); choice != "" {
+			choices = append(choices, choice)
+		}
+	}
+
+	return choices
+}
+
+// Get the names of the groups (the comma separated values of the "exclusive"
+// tag) in which at most one field may be set.
+func (f Field) Exclusive() []string {
+	return f.tagList(tagExclusive)
+}
+
+// Get the names of the groups (the comma separated values of the "together"
+// tag) in which either all or none of the fields must be set.
+func (f Field) Together() []string {
+	return f.tagList(tagTogether)
+}
+
+// Get the names of the groups (the comma separated values of the
+// "required-any" tag) in which at least one field must be set.
+func (f Field) RequiredAny() []string {
+	return f.tagList(tagRequiredAny)
+}
+
+// Get the interactive prompt question (the value of the "prompt" tag).
+func (f Field) Prompt() string {
+	return f.structField.Tag.Get(tagPrompt)
+}
+
+// True if the field value is secret (the "secret" tag is "true"). Secret
+// values are prompted for without echo.
+func (f Field) IsSecret() bool {
+	return f.structField.Tag.Get(tagSecret) == "true"
+}
+
+// True if the field value is the path of a JSON config file (the "config"
+// tag is "true"). Use the "default" tag to set a well-known path.
+func (f Field) IsConfig() bool {
+	return f.structField.Tag.Get(tagConfig) == "true"
+}
+
+// Get the validation rules (the value of the "validate" tag).
+func (f Field) Validate() string {
+	return f.structField.Tag.Get(tagValidate)
+}
+
+// Get the Go type of the struct field.
+func (f Field) Type() reflect.Type {
+	return f.structField.Type
+}
+
+// True if the field counts occurrences of the flag (the "count" tag is
+// "true"). Count flags do not take a value. Only integer fields can be count
+// fields. Parsing fails for other fields with a "count" tag (see
+// [Field.checkCount]).
+func (f Field) IsCount() bool {
+	return f.structField.Tag.Get(tagCount) == "true" && fieldIsInteger(f.structField.Type)
+}
+
+// Return an error if the field has a "count" tag but is not an integer.
+func (f Field) checkCount() error {
+	if f.structField.Tag.Get(tagCount) == "true" && !fieldIsInteger(f.structField.Type) {
+		return fmt.Errorf("count flag %s must be an integer, not %s", f.label(), f.structField.Type)
+	}
+
+	return nil
+}
+
+// True if the field is a boolean with generated "--no-<name>" negations (the
+// "negatable" tag is "true").
+func (f Field) IsNegatable() bool {
+	return f.structField.Tag.Get(tagNegatable) == "true"
+}
+
+// True if the named flag is set without a value (booleans and counts).
+func (f Field) IsValueless() bool {
+	return f.IsCount() || f.DecodeType() == reflect.TypeFor[bool]()
+}
+
+// Get the element type of the struct field. If the struct field is a slice or
+// map, return the element type. This is the type used to decode individual
 // values.
 func (f Field) DecodeType() reflect.Type {
-	if f.IsSlice() {
+	if f.IsSlice() || f.IsMap() {
 		return f.structField.Type.Elem()
 	}
 
 	return f.structField.Type
 }
 
-// True if the struct field is a slice.
+// Get the key type of a map struct field, or nil if the field is not a map.
+func (f Field) KeyType() reflect.Type {
+	if f.IsMap() {
+		return f.structField.Type.Key()
+	}
+
+	return nil
+}
+
+// True if the struct field is a slice. Byte slices are decoded as a single
+// value, so they are not considered slices.
 func (f Field) IsSlice() bool {
-	return f.structField.Type.Kind() == reflect.Slice
+	return f.structField.Type.Kind() == reflect.Slice && f.structField.Type != reflect.TypeFor[[]byte]()
+}
+
+// True if the struct field is a map. Map values are set from "key=value"
+// strings.
+func (f Field) IsMap() bool {
+	return f.structField.Type.Kind() == reflect.Map
+}
+
+func (f Field) argsTag(tag string, fallback int) int {
+	text, ok := f.structField.Tag.Lookup(tag)
+
+	if !ok {
+		return fallback
+	}
+
+	value, err := strconv.Atoi(text)
+
+	if err != nil || value < 0 {
+		panic(fmt.Sprintf("invalid %s tag %q for %s", tag, text, f.Name()))
+	}
+
+	return value
+}
+
+func (f Field) tagList(tag string) []string {
+	values := []string{}
+
+	for value := range strings.SplitSeq(f.structField.Tag.Get(tag), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// Get a short label for the field used in messages. Named flags use their
+// last (usually longest) name, and positional fields use the flag tag.
+func (f Field) label() string {
+	var label string
+
+	for name := range f.FlagNames() {
+		label = formatFlagName(name)
+	}
+
+	return shorthand.Coalesce(label, f.Flag())
+}
+
+// Reset the target struct field to its zero value.
+func (f Field) reset(target reflect.Value) {
+	field := target.Elem().FieldByIndex(f.structField.Index)
+	field.SetZero()
+}
+
+// Get the value of the target struct field.
+func (f Field) get(target reflect.Value) reflect.Value {
+	return target.Elem().FieldByIndex(f.structField.Index)
 }
 
 // Set the value of the target struct field. If the struct field is a slice,
@@ -94,3 +399,39 @@ func (f Field) Set(target reflect.Value, value any) {
 		field.Set(reflect.ValueOf(value))
 	}
 }
+
+// Set a key and value in the target map struct field, creating the map if it
+// is nil.
+func (f Field) SetEntry(target reflect.Value, key any, value any) {
+	if f.structPtrType != target.Type().Elem() {
+		panic(fmt.Sprintf("field struct type %s does not match target type %s", f.structPtrType, target.Type().Elem()))
+	}
+
+	field := target.Elem().FieldByIndex(f.structField.Index)
+
+	if field.IsNil() {
+		field.Set(reflect.MakeMap(f.structField.Type))
+	}
+
+	field.SetMapIndex(reflect.ValueOf(key), reflect.ValueOf(value))
+}
+
+func fieldIsInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	default:
+		return false
+	}
+}
+
+// Format a flag name with a single hyphen prefix if it is one character long,
+// or a double hyphen prefix otherwise.
+func formatFlagName(name string) string {
+	if len(name) == 1 {
+		return "-" + name
+	}
+
+	return "--" + name
+}
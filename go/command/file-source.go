@@ -0,0 +1,128 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// File source field value, set from a file path, "@path", or "-" (stdin). The
+// command opens the source before the action runs, and closes it after the
+// action returns. Fields of type [io.Reader] also accept "@path" and "-"
+// values, which are decoded as file sources.
+type FileSource struct {
+	// File path, or "-" for stdin.
+	Path string
+
+	// Reader for the opened source.
+	io.Reader
+
+	file *os.File
+}
+
+func (s *FileSource) UnmarshalText(text []byte) error {
+	s.Path = strings.TrimPrefix(string(text), "@")
+
+	if s.Path == "" {
+		return fmt.Errorf("missing file path")
+	}
+
+	return nil
+}
+
+// Open the source, if it is not already open.
+func (s *FileSource) Open() error {
+	if s.Reader != nil {
+		return nil
+	}
+
+	if s.Path == "-" {
+		s.Reader = os.Stdin
+		return nil
+	}
+
+	file, err := os.Open(s.Path)
+
+	if err != nil {
+		return err
+	}
+
+	s.Reader = file
+	s.file = file
+
+	return nil
+}
+
+// Close the source. Stdin is not closed.
+func (s *FileSource) Close() error {
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+
+	return err
+}
+
+// True if the value is a file source value (eg. "@path" or "-").
+func isFileSourceValue(value string) bool {
+	return value == "-" || strings.HasPrefix(value, "@")
+}
+
+// Open all file sources in the fields of the target struct pointer. Returns a
+// function that closes the opened sources.
+func openFileSources(target any) (closeAll func() error, err error) {
+	sources := []*FileSource{}
+	closeAll = func() error {
+		errs := []error{}
+
+		for _, source := range sources {
+			errs = append(errs, source.Close())
+		}
+
+		return errors.Join(errs...)
+	}
+
+	value := reflect.ValueOf(target)
+
+	for field := range FieldIterator(value.Type().Elem()) {
+		for source := range fileSources(field.get(value)) {
+			if err := source.Open(); err != nil {
+				closeAll()
+				return nil, fmt.Errorf("failed opening %s: %w", field.label(), err)
+			}
+
+			sources = append(sources, source)
+		}
+	}
+
+	return closeAll, nil
+}
+
+// Yield the file sources of a field value, which may be a file source, an
+// [io.Reader] containing a file source, or a slice of either.
+func fileSources(value reflect.Value) iter.Seq[*FileSource] {
+	return func(yield func(*FileSource) bool) {
+		switch {
+		case value.Kind() == reflect.Slice && value.Type() != reflect.TypeFor[[]byte]():
+			for i := range value.Len() {
+				for source := range fileSources(value.Index(i)) {
+					if !yield(source) {
+						return
+					}
+				}
+			}
+		case value.Type() == reflect.TypeFor[FileSource]():
+			yield(value.Addr().Interface().(*FileSource))
+		case value.Kind() == reflect.Interface && !value.IsNil():
+			if source, ok := value.Interface().(*FileSource); ok {
+				yield(source)
+			}
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package command
+
+import "os"
+
+// Default getter which looks up process environment variables.
+var GetterDefault Getter = Get(func(name string) (string, bool) {
+	return os.LookupEnv(name)
+})
@@ -0,0 +1,14 @@
+package command
+
+// Look up an environment variable value by name.
+type Getter interface {
+	// Look up an environment variable value by name.
+	Get(name string) (value string, ok bool)
+}
+
+// Look up an environment variable value by name.
+type Get func(name string) (value string, ok bool)
+
+func (g Get) Get(name string) (value string, ok bool) {
+	return g(name)
+}
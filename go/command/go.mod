@@ -4,6 +4,8 @@ go 1.25.3
 
 require (
 	github.com/go-playground/validator/v10 v10.30.1
+	go.yaml.in/yaml/v3 v3.0.4
+	golang.org/x/term v0.41.0
 	seahax.com/go/assert v0.0.4
 	seahax.com/go/shorthand v0.0.16
 )
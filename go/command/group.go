@@ -0,0 +1,126 @@
+package command
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"seahax.com/go/shorthand"
+)
+
+type groupKind int
+
+const (
+	// At most one field in the group may be set.
+	groupExclusive groupKind = iota
+	// Either all or none of the fields in the group must be set.
+	groupTogether
+	// At least one field in the group must be set.
+	groupRequiredAny
+)
+
+// Named group of fields with a constraint on which fields are set, declared
+// with the "exclusive", "together", and "required-any" field tags.
+type fieldGroup struct {
+	kind   groupKind
+	name   string
+	fields []Field
+}
+
+// Return the field groups of the struct type, in the order that they are first
+// referenced.
+func fieldGroups(structType reflect.Type) []*fieldGroup {
+	groups := []*fieldGroup{}
+
+	add := func(kind groupKind, names []string, field Field) {
+		for _, name := range names {
+			// Group names are scoped to the (nested) struct declaring them.
+			name = field.scope + name
+			index := slices.IndexFunc(groups, func(group *fieldGroup) bool {
+				return group.kind == kind && group.name == name
+			})
+
+			if index < 0 {
+				index = len(groups)
+				groups = append(groups, &fieldGroup{kind: kind, name: name})
+			}
+
+			groups[index].fields = append(groups[index].fields, field)
+		}
+	}
+
+	for field := range FieldIterator(structType) {
+		add(groupExclusive, field.Exclusive(), field)
+		add(groupTogether, field.Together(), field)
+		add(groupRequiredAny, field.RequiredAny(), field)
+	}
+
+	return groups
+}
+
+// Return an error if the group constraint is not satisfied.
+func (g *fieldGroup) check(isSet func(field Field) bool) error {
+	set := shorthand.Filter(g.fields, func(_ int, field Field) bool {
+		return isSet(field)
+	})
+
+	switch g.kind {
+	case groupExclusive:
+		if len(set) > 1 {
+			return fmt.Errorf("%s cannot be used together", groupLabels(set, "and"))
+		}
+	case groupTogether:
+		if len(set) > 0 && len(set) < len(g.fields) {
+			missing := shorthand.Filter(g.fields, func(_ int, field Field) bool {
+				return !isSet(field)
+			})
+
+			return fmt.Errorf("%s must be used with %s", groupLabels(missing, "and"), groupLabels(set, "and"))
+		}
+	case groupRequiredAny:
+		if len(set) == 0 {
+			if len(g.fields) == 1 {
+				return fmt.Errorf("%s is required", groupLabels(g.fields, "or"))
+			}
+
+			return fmt.Errorf("one of %s is required", groupLabels(g.fields, "or"))
+		}
+	}
+
+	return nil
+}
+
+// Return the group description for help text.
+func (g *fieldGroup) help() (key string, text string) {
+	key = strings.Join(shorthand.Select(g.fields, func(_ int, field Field) string {
+		return field.label()
+	}), ", ")
+
+	switch g.kind {
+	case groupExclusive:
+		text = "Mutually exclusive"
+	case groupTogether:
+		text = "Must be used together"
+	case groupRequiredAny:
+		text = "At least one is required"
+	}
+
+	return key, text
+}
+
+// Join field labels as a list (eg. "--a, --b, and --c").
+func groupLabels(fields []Field, conjunction string) string {
+	labels := shorthand.Select(fields, func(_ int, field Field) string {
+		return field.label()
+	})
+
+	switch len(labels) {
+	case 1:
+		return labels[0]
+	case 2:
+		return fmt.Sprintf("%s %s %s", labels[0], conjunction, labels[1])
+	default:
+		return fmt.Sprintf("%s, %s %s", strings.Join(labels[:len(labels)-1], ", "), conjunction, labels[len(labels)-1])
+	}
+}
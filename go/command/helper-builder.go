@@ -1,13 +1,41 @@
 package command
 
-import "strings"
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+const (
+	helperStyleHeading string = "\x1b[1m"
+	helperStyleKey     string = "\x1b[36m"
+	helperStyleReset   string = "\x1b[0m"
+
+	// Compact layout list item keys longer than this are written on their own
+	// line, so that they do not push the text column too far to the right.
+	helperCompactKeyMax int = 32
+)
 
 // Help string builder.
 type HelperBuilder struct {
+	// Wrap paragraphs and list item text to this line width. Zero disables
+	// wrapping.
+	Width int
+	// Style headings and list item keys with ANSI escape sequences.
+	Style bool
+	// Write list item text on the same line as the key, aligned in a column,
+	// instead of on the following line.
+	Compact bool
+
 	ub      strings.Builder
 	b       strings.Builder
 	section string
 	heading string
+	items   [][2]string
 }
 
 // Create a new [HelperBuilder].
@@ -15,6 +43,35 @@ func NewHelperBuilder() HelperBuilder {
 	return HelperBuilder{}
 }
 
+// Create a new [HelperBuilder] for help text written to the output. If the
+// output is a terminal, text is wrapped to the terminal width (or the COLUMNS
+// environment variable), and styled unless the NO_COLOR environment variable
+// is set.
+func NewTerminalHelperBuilder(output io.Writer, getter Getter) HelperBuilder {
+	b := NewHelperBuilder()
+	file, ok := output.(*os.File)
+
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		return b
+	}
+
+	if width, _, err := term.GetSize(int(file.Fd())); err == nil {
+		b.Width = width
+	}
+
+	if value, ok := getter.Get("COLUMNS"); ok {
+		if width, err := strconv.Atoi(value); err == nil && width > 0 {
+			b.Width = width
+		}
+	}
+
+	if value, ok := getter.Get("NO_COLOR"); !ok || value == "" {
+		b.Style = true
+	}
+
+	return b
+}
+
 func (h *HelperBuilder) HasUsage() bool {
 	return h.ub.Len() > 0
 }
@@ -35,13 +92,14 @@ func (h *HelperBuilder) WriteParagraph(s string) {
 		return
 	}
 
+	h.flush()
 	h.section = "paragraph"
 
 	if h.b.Len() > 0 {
 		h.b.WriteString("\n")
 	}
 
-	h.b.WriteString(s)
+	h.b.WriteString(helperWrap(s, h.Width))
 	h.b.WriteString("\n")
 }
 
@@ -51,6 +109,7 @@ func (h *HelperBuilder) WriteListHeading(s string) {
 		return
 	}
 
+	h.flush()
 	h.section = "list"
 	h.heading = s
 }
@@ -61,41 +120,122 @@ func (h *HelperBuilder) WriteListItem(key string, s string) {
 		return
 	}
 
+	h.items = append(h.items, [2]string{key, s})
+}
+
+// Get the result and reset the builder.
+func (h *HelperBuilder) String() string {
+	h.flush()
+
+	if h.ub.Len() > 0 && h.b.Len() > 0 {
+		h.ub.WriteString("\n")
+	}
+
+	h.ub.WriteString(h.b.String())
+	str := h.ub.String()
+	h.ub.Reset()
+	h.b.Reset()
+	h.section = ""
+	h.heading = ""
+	return str
+}
+
+// Write the pending list heading and items. List items are buffered so that
+// the compact layout can align the text column.
+func (h *HelperBuilder) flush() {
+	if len(h.items) == 0 {
+		return
+	}
+
 	if h.b.Len() > 0 && (h.section != "list" || h.heading != "") {
 		h.b.WriteString("\n")
 	}
 
 	if h.heading != "" {
-		h.b.WriteString(h.heading)
+		h.b.WriteString(h.style(helperStyleHeading, h.heading))
+		h.b.WriteString("\n")
+	}
+
+	column := 6
+
+	if h.Compact {
+		column = 0
+
+		for _, item := range h.items {
+			if length := utf8.RuneCountInString(item[0]); length <= helperCompactKeyMax {
+				column = max(column, length)
+			}
+		}
+
+		column += 4
+	}
+
+	for _, item := range h.items {
+		key, s := item[0], item[1]
+		length := utf8.RuneCountInString(key)
+		indent := strings.Repeat(" ", column)
+
+		h.b.WriteString("  ")
+		h.b.WriteString(h.style(helperStyleKey, key))
+
+		if length <= column-4 {
+			h.b.WriteString(strings.Repeat(" ", column-2-length))
+		} else {
+			h.b.WriteString("\n")
+			h.b.WriteString(indent)
+		}
+
+		h.b.WriteString(strings.ReplaceAll(helperWrap(s, h.Width-column), "\n", "\n"+indent))
 		h.b.WriteString("\n")
 	}
 
 	h.section = "list"
 	h.heading = ""
-	h.b.WriteString("  ")
-	h.b.WriteString(key)
+	h.items = nil
+}
 
-	if len(key) <= 2 {
-		h.b.WriteString(strings.Repeat(" ", 4-len(key)))
-	} else {
-		h.b.WriteString("\n      ")
+func (h *HelperBuilder) style(style string, s string) string {
+	if !h.Style {
+		return s
 	}
 
-	h.b.WriteString(strings.ReplaceAll(s, "\n", "\n      "))
-	h.b.WriteString("\n")
+	return style + s + helperStyleReset
 }
 
-// Get the result and reset the builder.
-func (h *HelperBuilder) String() string {
-	if h.ub.Len() > 0 && h.b.Len() > 0 {
-		h.ub.WriteString("\n")
+// Wrap each line of the text at word boundaries so that lines are no longer
+// than the width, if possible. Words longer than the width are not broken.
+// Lines which are indented (eg. examples) or already fit are left unchanged. A
+// width less than one disables wrapping.
+func helperWrap(s string, width int) string {
+	if width < 1 {
+		return s
 	}
 
-	h.ub.WriteString(h.b.String())
-	str := h.ub.String()
-	h.ub.Reset()
-	h.b.Reset()
-	h.section = ""
-	h.heading = ""
-	return str
+	lines := []string{}
+
+	for line := range strings.SplitSeq(s, "\n") {
+		if utf8.RuneCountInString(line) <= width || strings.TrimLeft(line, " \t") != line {
+			lines = append(lines, line)
+			continue
+		}
+
+		current := ""
+
+		for word := range strings.FieldsSeq(line) {
+			if current != "" && utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) > width {
+				lines = append(lines, current)
+				current = ""
+			}
+
+			if current != "" {
+				current += " "
+			}
+
+			current += word
+		}
+
+		lines = append(lines, current)
+	}
+
+	return strings.Join(lines, "\n")
 }
@@ -1,13 +1,33 @@
 package command
 
-import "fmt"
+import (
+	"fmt"
+	"iter"
+	"maps"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
 
-// Default help string factory.
+	"seahax.com/go/shorthand"
+)
+
+// Default help string factory. When the command output is a terminal, the
+// help text is wrapped to the terminal width and styled.
 var HelperDefault Helper = Help(func(command CommandImmutable) string {
-	b := NewHelperBuilder()
-	hasOptions := false
-	hasArguments := false
-	hasCommands := false
+	return helpDefault(command, false)
+})
+
+// Compact help string factory. Like [HelperDefault], but list item text is
+// aligned in a column on the same line as the option or command name.
+var HelperCompact Helper = Help(func(command CommandImmutable) string {
+	return helpDefault(command, true)
+})
+
+func helpDefault(command CommandImmutable, compact bool) string {
+	b := NewTerminalHelperBuilder(command.Output(), command.Getter())
+	b.Compact = compact
+	options, arguments := helpFields(command)
 
 	b.WriteParagraph(command.Summary())
 
@@ -15,69 +35,233 @@ var HelperDefault Helper = Help(func(command CommandImmutable) string {
 		b.WriteParagraph(prologue)
 	}
 
-	b.WriteListHeading("Options:")
-	positionals := []Field{}
+	for _, section := range helpSections(options) {
+		b.WriteListHeading(shorthand.Coalesce(section, "Options") + ":")
 
-	for field := range FieldIterator(command.Type()) {
-		help := field.Help()
-
-		if help == "" {
-			// Fields without a help tag are hidden from help text.
-			continue
+		for _, field := range options {
+			if field.Section() == section {
+				b.WriteListItem(helpFieldKey(command, field), helpFieldText(field))
+			}
 		}
 
-		if !field.IsNamedFlag() {
-			positionals = append(positionals, field)
-			continue
+		if section == "" {
+			for flag, help := range helpFlagActions(command) {
+				b.WriteListItem(flag, help)
+			}
 		}
+	}
 
-		b.WriteListItem(field.Flag(), help)
-		hasOptions = true
+	b.WriteListHeading("Global options:")
+
+	for _, field := range helpGlobalFields(command) {
+		b.WriteListItem(helpFieldKey(command, field), helpFieldText(field))
 	}
 
 	b.WriteListHeading("Arguments:")
 
-	for _, field := range positionals {
-		b.WriteListItem(field.Flag(), field.Help())
-		hasArguments = true
+	for _, field := range arguments {
+		b.WriteListItem(helpFieldKey(command, field), helpFieldText(field))
+	}
+
+	b.WriteListHeading("Constraints:")
+
+	// Groups of persistent structs are checked by every descendant command.
+	for _, structType := range append([]reflect.Type{command.Type()}, persistentTypes(command)...) {
+		for _, group := range fieldGroups(structType) {
+			b.WriteListItem(group.help())
+		}
 	}
 
 	b.WriteListHeading("Commands:")
 
-	for subcommand := range command.Subcommands() {
-		summary := subcommand.Summary()
+	for _, subcommand := range helpSubcommands(command) {
+		b.WriteListItem(subcommand.Name(), subcommand.Summary())
+	}
+
+	if command.Plugins() {
+		b.WriteListHeading("Plugin commands:")
 
-		if summary != "" {
-			hasCommands = true
-			b.WriteListItem(subcommand.Name(), summary)
+		for _, plugin := range pluginList(command) {
+			b.WriteListItem(plugin.name, plugin.path)
 		}
 	}
 
-	for usage := range command.Usage() {
+	for _, usage := range helpUsage(command) {
 		b.WriteUsage(usage)
 	}
 
-	if !b.HasUsage() {
-		if hasOptions && hasArguments {
-			b.WriteUsage(fmt.Sprintf("Usage: %s <options> <arguments>", command.Fullname()))
-		} else if hasOptions {
-			b.WriteUsage(fmt.Sprintf("Usage: %s <options>", command.Fullname()))
-		} else if hasArguments {
-			b.WriteUsage(fmt.Sprintf("Usage: %s <arguments>", command.Fullname()))
+	for epilogue := range command.Epilogue() {
+		b.WriteParagraph(epilogue)
+	}
+
+	return b.String()
+}
+
+// Return the named (options) and positional (arguments) fields which should
+// be included in help text.
+func helpFields(command CommandImmutable) (options []Field, arguments []Field) {
+	for field := range FieldIterator(command.Type()) {
+		if !helpIsVisible(field) {
+			continue
 		}
 
-		if hasCommands {
-			b.WriteUsage(fmt.Sprintf("Usage: %s <command> ...", command.Fullname()))
+		if field.IsNamedFlag() {
+			options = append(options, field)
+		} else {
+			arguments = append(arguments, field)
 		}
 	}
 
-	if !b.HasUsage() {
-		b.WriteUsage(fmt.Sprintf("Usage: %s", command.Fullname()))
+	return options, arguments
+}
+
+// Return the unique help sections of the fields, starting with the command
+// struct's own (empty) section, followed by nested struct sections in order.
+func helpSections(fields []Field) []string {
+	sections := []string{""}
+
+	for _, field := range fields {
+		if !slices.Contains(sections, field.Section()) {
+			sections = append(sections, field.Section())
+		}
 	}
 
-	for epilogue := range command.Epilogue() {
-		b.WriteParagraph(epilogue)
+	return sections
+}
+
+// Return the flag actions with help text, which should be included in help
+// text.
+func helpFlagActions(command CommandImmutable) iter.Seq2[string, string] {
+	return func(yield func(string, string) bool) {
+		for flag, help := range command.FlagActions() {
+			if help != "" && !yield(flag, help) {
+				return
+			}
+		}
 	}
+}
 
-	return b.String()
-})
+// Return the persistent option fields (declared by the command or its parents)
+// which should be included in help text.
+func helpGlobalFields(command CommandImmutable) []Field {
+	fields := []Field{}
+
+	for _, structType := range persistentTypes(command) {
+		for field := range FieldIterator(structType) {
+			if helpIsVisible(field) && field.IsNamedFlag() {
+				fields = append(fields, field)
+			}
+		}
+	}
+
+	return fields
+}
+
+// True if the field should be included in help text. Fields without a help
+// tag are also hidden.
+func helpIsVisible(field Field) bool {
+	return field.Help() != "" && !field.IsHidden() && field.Deprecated() == ""
+}
+
+// Return the subcommands which should be included in help text.
+func helpSubcommands(command CommandImmutable) []CommandImmutable {
+	subcommands := []CommandImmutable{}
+
+	for subcommand := range command.Subcommands() {
+		if subcommand.Summary() == "" || subcommand.IsHidden() || subcommand.Deprecated() != "" {
+			// Subcommands without a summary are hidden from help text.
+			continue
+		}
+
+		subcommands = append(subcommands, subcommand)
+	}
+
+	return subcommands
+}
+
+// Return the explicit command usage lines, or generate usage lines if none are
+// set.
+func helpUsage(command CommandImmutable) []string {
+	usage := slices.DeleteFunc(slices.Collect(command.Usage()), func(s string) bool {
+		return s == ""
+	})
+
+	if len(usage) > 0 {
+		return usage
+	}
+
+	options, _ := helpFields(command)
+	hasOptions := len(options) > 0 || len(maps.Collect(helpFlagActions(command))) > 0
+	arguments := helpUsageArguments(command)
+	hasArguments := arguments != ""
+
+	if hasOptions && hasArguments {
+		usage = append(usage, fmt.Sprintf("Usage: %s <options> %s", command.Fullname(), arguments))
+	} else if hasOptions {
+		usage = append(usage, fmt.Sprintf("Usage: %s <options>", command.Fullname()))
+	} else if hasArguments {
+		usage = append(usage, fmt.Sprintf("Usage: %s %s", command.Fullname(), arguments))
+	}
+
+	if len(helpSubcommands(command)) > 0 || command.Plugins() {
+		usage = append(usage, fmt.Sprintf("Usage: %s <command> ...", command.Fullname()))
+	}
+
+	if len(usage) == 0 {
+		usage = append(usage, fmt.Sprintf("Usage: %s", command.Fullname()))
+	}
+
+	return usage
+}
+
+// Return the positional arguments for the usage line (eg. "<src> [dst]
+// [files...]"). Unlike the arguments list, arguments without help text are
+// included, so that the usage line reflects what the parser requires.
+func helpUsageArguments(command CommandImmutable) string {
+	arguments := []string{}
+
+	for field := range FieldIterator(command.Type()) {
+		if !field.IsNamedFlag() && !field.IsHidden() && field.Deprecated() == "" {
+			arguments = append(arguments, field.Flag())
+		}
+	}
+
+	return strings.Join(arguments, " ")
+}
+
+func helpFieldKey(command CommandImmutable, field Field) string {
+	flag := field.Flag()
+
+	if field.IsNegatable() {
+		for name := range field.FlagNames() {
+			if len(name) > 1 {
+				pattern := regexp.MustCompile(`--` + regexp.QuoteMeta(name) + `([^a-zA-Z0-9-]|$)`)
+				flag = pattern.ReplaceAllString(flag, "--[no-]"+name+"$1")
+			}
+		}
+	}
+
+	if env := field.Env(); env != "" {
+		return fmt.Sprintf("%s [env: %s%s]", flag, command.EnvPrefix(), env)
+	}
+
+	return flag
+}
+
+func helpFieldText(field Field) string {
+	text := field.Help()
+
+	if field.IsCount() {
+		text += " (repeatable)"
+	}
+
+	if choices := field.Choices(); len(choices) > 0 {
+		text += fmt.Sprintf(" (choices: %s)", strings.Join(choices, ", "))
+	}
+
+	if value := field.Default(); value != "" {
+		text += fmt.Sprintf(" (default: %s)", value)
+	}
+
+	return text
+}
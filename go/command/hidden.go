@@ -0,0 +1,17 @@
+package command
+
+// Hide the command from help text, completions, and documentation. Hidden
+// commands can still be run.
+func Hidden() Modifier {
+	return Modify(func(command CommandMutable) {
+		command.SetHidden(true)
+	})
+}
+
+// Mark the command deprecated. Deprecated commands are hidden (see [Hidden]),
+// and print a warning with the message to the output writer when run.
+func Deprecated(message string) Modifier {
+	return Modify(func(command CommandMutable) {
+		command.SetDeprecated(message)
+	})
+}
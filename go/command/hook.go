@@ -0,0 +1,64 @@
+package command
+
+import (
+	"context"
+	"slices"
+)
+
+// Command action with the resolved (running) command and its parsed options
+// struct pointer.
+type Action func(ctx context.Context, command CommandImmutable, opts any) error
+
+// Action middleware which returns an action that wraps the next action.
+type Middleware func(next Action) Action
+
+// Wrap the action of the command and all of its subcommands. Middleware added
+// to a parent command wraps middleware added to its subcommands. Returning an
+// error (eg. an [*Error]) without calling next short-circuits the action.
+// Built-in subcommands (eg. "completion", "shell", and "version") and
+// [Namespace] commands are not wrapped, so the options are always those of a
+// command created by the application.
+func Wrap(middleware Middleware) Modifier {
+	return Modify(func(command CommandMutable) {
+		command.AddMiddleware(middleware)
+	})
+}
+
+// Run a hook before the action of the command and all of its subcommands. If
+// the hook returns an error, the action is not run.
+func PreRun(hook func(ctx context.Context, command CommandImmutable, opts any) error) Modifier {
+	return Wrap(func(next Action) Action {
+		return func(ctx context.Context, command CommandImmutable, opts any) error {
+			if err := hook(ctx, command, opts); err != nil {
+				return err
+			}
+
+			return next(ctx, command, opts)
+		}
+	})
+}
+
+// Run a hook after the action of the command and all of its subcommands. The
+// hook receives the action error (or nil), and its return value replaces the
+// action error.
+func PostRun(hook func(ctx context.Context, command CommandImmutable, opts any, err error) error) Modifier {
+	return Wrap(func(next Action) Action {
+		return func(ctx context.Context, command CommandImmutable, opts any) error {
+			return hook(ctx, command, opts, next(ctx, command, opts))
+		}
+	})
+}
+
+// Wrap the action with the middleware of the command and its parents, with
+// the root command's middleware outermost.
+func hookWrap(command CommandImmutable, action Action) Action {
+	for _, middleware := range slices.Backward(slices.Collect(command.Middleware())) {
+		action = middleware(action)
+	}
+
+	if parent := command.Parent(); parent != nil {
+		action = hookWrap(parent, action)
+	}
+
+	return action
+}
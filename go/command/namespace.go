@@ -1,19 +1,36 @@
 package command
 
-import "fmt"
+import (
+	"context"
+	"fmt"
+)
 
 type namespaceOpts struct {
-	Extra []string `flag:"<extra...>"`
+	Extra []string `flag:"[extra...]" hidden:"true"`
 }
 
 // Create a new [Command] that does not have an action and requires a
 // subcommand.
 func Namespace(name string, summary string, modifiers ...Modifier) Command[namespaceOpts] {
-	return New(name, summary, func(opts *namespaceOpts) error {
+	// Stop parsing at the first positional argument, so that the invalid
+	// subcommand is reported instead of any flags that follow it.
+	modifiers = append([]Modifier{Modify(func(command CommandMutable) {
+		command.SetParseMode(ParseModeGo)
+	})}, modifiers...)
+
+	cmd := newCommand(name, summary, func(ctx context.Context, opts *namespaceOpts) error {
 		if len(opts.Extra) > 0 {
-			return NewError(fmt.Errorf("invalid subcommand %q", opts.Extra[0]), true)
+			return &Error{
+				error:          fmt.Errorf("invalid subcommand %q", opts.Extra[0]),
+				IsParseFailure: true,
+				Suggestions:    suggestSubcommands(opts.Extra[0], ContextCommand(ctx)),
+			}
 		}
 
 		return NewError(fmt.Errorf("missing required subcommand"), true)
-	}, modifiers...)
+	}, false, modifiers)
+
+	// The namespace action only reports a missing or invalid subcommand.
+	cmd.noMiddleware = true
+	return cmd
 }
@@ -0,0 +1,17 @@
+package command
+
+// Named (flag) argument parsing mode.
+type ParseMode int
+
+const (
+	// GNU style parsing. Flags and positional arguments can be interspersed,
+	// single character flags can be bundled (eg. "-abc"), values can be
+	// attached to short flags (eg. "-ofile") and long flags (eg.
+	// "--opt=value"), and "--" terminates flag parsing.
+	ParseModeGNU ParseMode = iota
+
+	// Go [flag] package style parsing. Flag parsing stops at the first
+	// positional argument or "--", and all flags can have one or two hyphen
+	// prefixes (eg. "-opt" or "--opt").
+	ParseModeGo
+)
@@ -1,15 +1,82 @@
 package command
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
 	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"seahax.com/go/shorthand"
 )
 
 // Parse command line arguments defined by struct field tags.
 type Parser struct {
 	StructType reflect.Type
 	Decoder    Decoder
+
+	// Factory for the initial value of the parsed struct, which must return a
+	// pointer to the struct type. Default tags are only applied to fields which
+	// are zero values in the initial value. Defaults to a new zero value if nil.
+	Initial func() any
+
+	// Environment variable getter used to fill fields with an "env" tag that
+	// are not set by the arguments. Defaults to [GetterDefault] if nil.
+	Getter Getter
+
+	// Prefix prepended to all "env" tag values.
+	EnvPrefix string
+
+	// Named argument parsing mode. Defaults to [ParseModeGNU].
+	Mode ParseMode
+
+	// Pointers to persistent option structs whose named flags are parsed along
+	// with the struct type's flags. Defaults and environment variables are not
+	// applied to persistent structs.
+	Persistent []any
+
+	// Prompter used to ask for values of fields with a "prompt" tag which are
+	// not set by arguments or environment variables. Prompting is disabled if
+	// nil.
+	Prompter Prompter
+
+	// Writer for deprecation warnings. Defaults to [os.Stderr] if nil.
+	Output io.Writer
+
+	// Stop parsing at the first positional argument, even in GNU mode.
+	stopAtPositional bool
+
+	// Persistent fields set by parent command levels (see [persistentValues]).
+	persistentSeen map[parserKey]bool
+
+	// Flags which stop parsing and select a command flag action (see
+	// [CommandMutable.SetFlagAction]).
+	actionFlags []string
+}
+
+// Returned by the parser when a flag action flag is found. The args are the
+// arguments following the flag.
+type parserActionError struct {
+	flag string
+	args []string
+}
+
+func (e *parserActionError) Error() string {
+	return fmt.Sprintf("flag action %s", e.flag)
+}
+
+// Parser field identity. Fields are keyed by struct type and field name,
+// because persistent struct field names may overlap.
+type parserKey struct {
+	structType reflect.Type
+	name       string
 }
 
 // Create a new [Parser].
@@ -20,61 +87,387 @@ func NewParser(structType reflect.Type, decoder Decoder) Parser {
 // Parse the command line arguments and return a new instance of the parser
 // type with the parsed values.
 func (p Parser) Parse(args []string) (parsedPtr any, err error) {
+	parsedPtr, _, err = p.ParseSources(args)
+	return parsedPtr, err
+}
+
+// Parse the command line arguments like [Parser.Parse], and also return the
+// [Source] of each field's final value, keyed by field name ([Field.Name]).
+// Values are resolved in layers: the initial value, default tags, the config
+// file, environment variables, and then the arguments. Prompts fill fields
+// which are still not set.
+func (p Parser) ParseSources(args []string) (parsedPtr any, sources map[string]Source, err error) {
 	target := reflect.New(p.StructType)
-	args, positionalFields, err := p.parseNamed(target, args)
+	seen := p.cloneSeen()
+	layers := map[parserKey]Source{}
+
+	layer := func(source Source) {
+		for key := range seen {
+			if _, ok := layers[key]; !ok {
+				layers[key] = source
+			}
+		}
+	}
+
+	if p.Initial != nil {
+		initial := reflect.ValueOf(p.Initial())
+
+		if initial.Type() != target.Type() {
+			panic(fmt.Sprintf("initial value type %s does not match parser type %s", initial.Type(), target.Type()))
+		}
+
+		target.Elem().Set(initial.Elem())
+	}
+
+	defaults, err := p.parseDefaults(target)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	args, positionalFields, err := p.parseNamed(target, seen, args)
 
 	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := p.parsePositional(target, seen, args, positionalFields); err != nil {
+		return nil, nil, err
+	}
+
+	layer(SourceArgs)
+
+	if err := p.parseEnv(target, seen); err != nil {
+		return nil, nil, err
+	}
+
+	layer(SourceEnv)
+
+	if err := p.parseConfig(target, seen); err != nil {
+		return nil, nil, err
+	}
+
+	layer(SourceConfig)
+
+	if err := p.parsePrompt(target, seen); err != nil {
+		return nil, nil, err
+	}
+
+	layer(SourcePrompt)
+
+	if err := p.parseArity(target, seen); err != nil {
+		return nil, nil, err
+	}
+
+	if err := p.parseGroups(seen); err != nil {
+		return nil, nil, err
+	}
+
+	sources = map[string]Source{}
+
+	for field := range FieldIterator(p.StructType) {
+		key := parserKey{p.StructType, field.Name()}
+
+		if source, ok := layers[key]; ok {
+			sources[field.Name()] = source
+		} else if defaults[key] {
+			sources[field.Name()] = SourceDefault
+		} else {
+			sources[field.Name()] = SourceInitial
+		}
+	}
+
+	return target.Interface(), sources, nil
+}
+
+// Parse only the leading persistent flags, stopping at the first positional
+// argument. The remaining arguments (starting with the positional argument)
+// are returned.
+func (p Parser) parseLeading(args []string) ([]string, map[parserKey]bool, error) {
+	p.StructType = reflect.TypeFor[struct{}]()
+	p.stopAtPositional = true
+	seen := p.cloneSeen()
+	args, _, err := p.parseNamed(reflect.New(p.StructType), seen, args)
+	return args, seen, err
+}
+
+// Return a new struct value with only defaults and environment variables
+// applied. Fields set by environment variables are added to seen as false, so
+// that they are considered set by group checks, but are still replaced (not
+// appended to) by arguments.
+func (p Parser) parseDefaultsAndEnv(seen map[parserKey]bool) (any, error) {
+	target := reflect.New(p.StructType)
+
+	if _, err := p.parseDefaults(target); err != nil {
 		return nil, err
 	}
 
-	if err := p.parsePositional(target, args, positionalFields); err != nil {
+	envSeen := map[parserKey]bool{}
+
+	if err := p.parseEnv(target, envSeen); err != nil {
 		return nil, err
 	}
 
+	for key := range envSeen {
+		seen[key] = false
+	}
+
 	return target.Interface(), nil
 }
 
-func (p Parser) parseNamed(target reflect.Value, args []string) ([]string, []Field, error) {
+func (p Parser) cloneSeen() map[parserKey]bool {
+	return shorthand.Coalesce(maps.Clone(p.persistentSeen), map[parserKey]bool{})
+}
+
+func (p Parser) parseNamed(target reflect.Value, seen map[parserKey]bool, args []string) ([]string, []Field, error) {
 	positionalFields := []Field{}
-	flagSet := flag.NewFlagSet("-", flag.ContinueOnError)
-	flagSet.Usage = func() {}
-	flagSet.SetOutput(&parseFlagSetWriter{})
+	fields := map[string]parserFlag{}
 
-	for field := range FieldIterator(p.StructType) {
-		if !field.IsNamedFlag() {
-			positionalFields = append(positionalFields, field)
+	var fieldErr error
+
+	addFields := func(target reflect.Value, isPersistent bool) {
+		for field := range FieldIterator(target.Type().Elem()) {
+			if err := field.checkCount(); err != nil && fieldErr == nil {
+				fieldErr = err
+			}
+
+			if !field.IsNamedFlag() {
+				if !isPersistent {
+					positionalFields = append(positionalFields, field)
+				}
+
+				continue
+			}
+
+			hasName := false
+
+			for name := range field.FlagNames() {
+				hasName = true
+				fields[name] = parserFlag{field: field, target: target, deprecated: field.Deprecated()}
+
+				if field.IsNegatable() && len(name) > 1 {
+					fields["no-"+name] = parserFlag{field: field, target: target, negate: true, deprecated: field.Deprecated()}
+				}
+			}
+
+			if !hasName {
+				panic(fmt.Sprintf("no valid flag names found in %q", field.Flag()))
+			}
+		}
+	}
+
+	for _, persistent := range p.Persistent {
+		addFields(reflect.ValueOf(persistent), true)
+	}
+
+	// Command flags take precedence over persistent flags with the same name.
+	addFields(target, false)
+
+	if fieldErr != nil {
+		return nil, nil, fieldErr
+	}
+
+	for name, flagField := range fields {
+		forward := flagField.field.Forward()
+
+		if forward == "" {
 			continue
 		}
 
-		hasName := false
+		forwardField, ok := fields[strings.TrimLeft(forward, "-")]
+
+		if !ok {
+			panic(fmt.Sprintf("forward flag %q not found for %q", forward, flagField.field.Flag()))
+		}
+
+		// Values of deprecated flags are set on the forward flag's field.
+		flagField.field = forwardField.field
+		flagField.target = forwardField.target
+		fields[name] = flagField
+	}
+
+	positional := []string{}
 
-		for name := range field.FlagNames() {
-			hasName = true
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
 
-			if field.DecodeType() == reflect.TypeFor[bool]() {
-				flagSet.BoolFunc(name, "", func(s string) error {
-					return p.set(target, field, s)
-				})
+		if arg == "--" {
+			if p.stopAtPositional {
+				positional = append(positional, args[i:]...)
 			} else {
-				flagSet.Func(name, "", func(s string) error {
-					return p.set(target, field, s)
-				})
+				positional = append(positional, args[i+1:]...)
 			}
+
+			break
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			if p.Mode == ParseModeGo || p.stopAtPositional {
+				// Go flag parsing stops at the first positional argument.
+				positional = append(positional, args[i:]...)
+				break
+			}
+
+			positional = append(positional, arg)
+			continue
 		}
 
-		if !hasName {
-			panic(fmt.Sprintf("no valid flag names found in %q", field.Flag()))
+		if slices.Contains(p.actionFlags, arg) {
+			return nil, nil, &parserActionError{flag: arg, args: args[i+1:]}
+		}
+
+		next := func() (string, bool) {
+			if i+1 < len(args) {
+				i++
+				return args[i], true
+			}
+
+			return "", false
+		}
+
+		// A single hyphen followed by a multi-character flag name is also a
+		// long flag, for compatibility with Go style flags.
+		name, _, _ := strings.Cut(arg[1:], "=")
+		_, isLongName := fields[name]
+		isLongName = isLongName && len(name) > 1
+		var err error
+
+		if p.Mode == ParseModeGo || arg[1] == '-' || isLongName {
+			err = p.parseLong(seen, fields, arg, next)
+		} else {
+			err = p.parseShort(seen, fields, arg, next)
+		}
+
+		if err != nil {
+			return nil, nil, err
 		}
 	}
 
-	if err := flagSet.Parse(args); err != nil {
-		return nil, nil, err
+	return positional, positionalFields, nil
+}
+
+// Parse a long flag (eg. "--name", "--name=value", or "--name value"). In Go
+// mode, a long flag may have a single hyphen prefix.
+func (p Parser) parseLong(seen map[parserKey]bool, fields map[string]parserFlag, arg string, next func() (string, bool)) error {
+	flagArg, value, hasValue := strings.Cut(arg, "=")
+	name := strings.TrimPrefix(flagArg[1:], "-")
+	flagField, ok := fields[name]
+	field := flagField.field
+
+	if !ok {
+		if name == "h" || name == "help" {
+			return flag.ErrHelp
+		}
+
+		return &UnknownFlagError{Flag: flagArg}
+	}
+
+	p.warnDeprecated(flagField, flagArg)
+
+	if flagField.negate && hasValue {
+		return fmt.Errorf("flag does not take a value: %s", flagArg)
+	}
+
+	if !hasValue {
+		if field.IsValueless() {
+			return p.setValueless(seen, flagField, flagArg)
+		} else if value, ok = next(); !ok {
+			return fmt.Errorf("flag needs an argument: %s", flagArg)
+		}
 	}
 
-	return flagSet.Args(), positionalFields, nil
+	if err := p.set(flagField.target, seen, field, value); err != nil {
+		return fmt.Errorf("invalid value %q for flag %s: %w", value, flagArg, err)
+	}
+
+	return nil
 }
 
-func (p Parser) parsePositional(target reflect.Value, args []string, fields []Field) error {
+// Parse bundled short flags (eg. "-abc"). The last flag in the bundle may
+// take a value, which is either attached (eg. "-ofile" or "-o=file") or the
+// next argument (eg. "-o file").
+func (p Parser) parseShort(seen map[parserKey]bool, fields map[string]parserFlag, arg string, next func() (string, bool)) error {
+	for j := 1; j < len(arg); j++ {
+		name := arg[j : j+1]
+		flagArg := "-" + name
+		flagField, ok := fields[name]
+		field := flagField.field
+
+		if !ok {
+			if name == "h" {
+				return flag.ErrHelp
+			}
+
+			return &UnknownFlagError{Flag: flagArg}
+		}
+
+		p.warnDeprecated(flagField, flagArg)
+
+		rest := arg[j+1:]
+
+		if field.IsValueless() && !strings.HasPrefix(rest, "=") {
+			if err := p.setValueless(seen, flagField, flagArg); err != nil {
+				return err
+			}
+		} else {
+			var value string
+
+			if rest = strings.TrimPrefix(rest, "="); rest != "" {
+				value = rest
+			} else if field.IsValueless() {
+				return fmt.Errorf("flag needs an argument: %s", flagArg)
+			} else if value, ok = next(); !ok {
+				return fmt.Errorf("flag needs an argument: %s", flagArg)
+			}
+
+			rest = ""
+
+			if err := p.set(flagField.target, seen, field, value); err != nil {
+				return fmt.Errorf("invalid value %q for flag %s: %w", value, flagArg, err)
+			}
+		}
+
+		if rest == "" {
+			break
+		}
+	}
+
+	return nil
+}
+
+// Print a warning if the flag is deprecated.
+func (p Parser) warnDeprecated(flagField parserFlag, flagArg string) {
+	if flagField.deprecated != "" {
+		fmt.Fprintf(shorthand.Coalesce[io.Writer](p.Output, os.Stderr), "warning: flag %s is deprecated: %s\n", flagArg, flagField.deprecated)
+	}
+}
+
+// Set a boolean, count, or negated flag which has no explicit value. Count
+// flags are incremented.
+func (p Parser) setValueless(seen map[parserKey]bool, flagField parserFlag, flagArg string) error {
+	field := flagField.field
+	value := strconv.FormatBool(!flagField.negate)
+
+	if field.IsCount() {
+		p.markSeen(flagField.target, seen, field)
+
+		if current := field.get(flagField.target); current.CanInt() {
+			value = strconv.FormatInt(current.Int()+1, 10)
+		} else {
+			value = strconv.FormatUint(current.Uint()+1, 10)
+		}
+	}
+
+	if err := p.set(flagField.target, seen, field, value); err != nil {
+		return fmt.Errorf("invalid value %q for flag %s: %w", value, flagArg, err)
+	}
+
+	return nil
+}
+
+func (p Parser) parsePositional(target reflect.Value, seen map[parserKey]bool, args []string, fields []Field) error {
+	parserCheckPositional(fields)
+
 	var last *Field
 
 	for _, field := range fields {
@@ -85,7 +478,7 @@ func (p Parser) parsePositional(target reflect.Value, args []string, fields []Fi
 		arg := args[0]
 		args = args[1:]
 
-		if err := p.set(target, field, arg); err != nil {
+		if err := p.set(target, seen, field, arg); err != nil {
 			return fmt.Errorf("invalid argument %q for %s: %w", arg, field.Flag(), err)
 		}
 
@@ -101,7 +494,7 @@ func (p Parser) parsePositional(target reflect.Value, args []string, fields []Fi
 	}
 
 	for _, arg := range args {
-		if err := p.set(target, *last, arg); err != nil {
+		if err := p.set(target, seen, *last, arg); err != nil {
 			return fmt.Errorf("invalid argument %q for %s: %w", arg, last.Flag(), err)
 		}
 	}
@@ -109,20 +502,369 @@ func (p Parser) parsePositional(target reflect.Value, args []string, fields []Fi
 	return nil
 }
 
-func (p Parser) set(target reflect.Value, field Field, value string) error {
+// Panic if the positional fields cannot be assigned unambiguously. Required
+// arguments must precede optional arguments, and only the last argument may be
+// variadic (a slice).
+func parserCheckPositional(fields []Field) {
+	for i, field := range fields {
+		if i > 0 && field.IsRequired() && !fields[i-1].IsRequired() {
+			panic(fmt.Sprintf("required argument %q follows optional argument %q", field.Flag(), fields[i-1].Flag()))
+		}
+
+		if field.IsSlice() && i < len(fields)-1 {
+			panic(fmt.Sprintf("variadic argument %q is not the last argument", field.Flag()))
+		}
+	}
+}
+
+// Check that required positional arguments have non-zero values, and that
+// variadic positional arguments have an allowed number of values.
+func (p Parser) parseArity(target reflect.Value, seen map[parserKey]bool) error {
+	for field := range FieldIterator(p.StructType) {
+		if field.IsNamedFlag() {
+			continue
+		}
+
+		if !field.IsSlice() {
+			if field.IsRequired() && !seen[parserKey{p.StructType, field.Name()}] && field.get(target).IsZero() {
+				return fmt.Errorf("missing argument %s", field.Flag())
+			}
+
+			continue
+		}
+
+		count := field.get(target).Len()
+		minArgs, maxArgs := field.ArgsRange()
+
+		if count < minArgs {
+			if count == 0 && minArgs == 1 {
+				return fmt.Errorf("missing argument %s", field.Flag())
+			}
+
+			return fmt.Errorf("too few arguments for %s: expected at least %d", field.Flag(), minArgs)
+		}
+
+		if maxArgs > 0 && count > maxArgs {
+			return fmt.Errorf("too many arguments for %s: expected at most %d", field.Flag(), maxArgs)
+		}
+	}
+
+	return nil
+}
+
+// Apply the default tag values to fields which are zero values, and return
+// the keys of the fields that were set.
+func (p Parser) parseDefaults(target reflect.Value) (map[parserKey]bool, error) {
+	defaults := map[parserKey]bool{}
+
+	for field := range FieldIterator(p.StructType) {
+		value := field.Default()
+
+		if value == "" || !field.get(target).IsZero() {
+			continue
+		}
+
+		defaults[parserKey{p.StructType, field.Name()}] = true
+
+		values := []string{value}
+
+		if field.IsSlice() || field.IsMap() {
+			values = strings.Split(value, ",")
+		}
+
+		for _, value := range values {
+			// Defaults are not marked as seen, so that they can be replaced.
+			if err := p.set(target, nil, field, value); err != nil {
+				return nil, fmt.Errorf("invalid default %q for %s: %w", value, field.Flag(), err)
+			}
+		}
+	}
+
+	return defaults, nil
+}
+
+func (p Parser) parseEnv(target reflect.Value, seen map[parserKey]bool) error {
+	getter := shorthand.Coalesce(p.Getter, GetterDefault)
+
+	for field := range FieldIterator(p.StructType) {
+		name := field.Env()
+
+		if name == "" || seen[parserKey{p.StructType, field.Name()}] {
+			// Arguments take precedence over environment variables.
+			continue
+		}
+
+		name = p.EnvPrefix + name
+		value, ok := getter.Get(name)
+
+		if !ok {
+			continue
+		}
+
+		values := []string{value}
+
+		if field.IsSlice() || field.IsMap() {
+			values = strings.Split(value, ",")
+		}
+
+		for _, value := range values {
+			if err := p.set(target, seen, field, value); err != nil {
+				return fmt.Errorf("failed parsing environment %q: %w", name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Fill fields which are not set by arguments or environment variables from
+// the JSON config file. The config file path is the value of the field with a
+// "config" tag. A missing config file is ignored unless the path was set by
+// arguments or environment variables.
+func (p Parser) parseConfig(target reflect.Value, seen map[parserKey]bool) error {
+	var path string
+	var explicit bool
+
+	for field := range FieldIterator(p.StructType) {
+		if field.IsConfig() {
+			path = field.get(target).String()
+			explicit = seen[parserKey{p.StructType, field.Name()}]
+			break
+		}
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	config, err := readConfig(path)
+
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		return err
+	}
+
+	for field := range FieldIterator(p.StructType) {
+		if field.IsConfig() || seen[parserKey{p.StructType, field.Name()}] {
+			continue
+		}
+
+		raw, ok := configLookup(config, field)
+
+		if !ok {
+			continue
+		}
+
+		if err := p.setConfig(target, seen, field, raw); err != nil {
+			return fmt.Errorf("invalid config value for %s in %s: %w", field.Name(), path, err)
+		}
+	}
+
+	return nil
+}
+
+// Set a field from a JSON config value. Strings, numbers, and booleans (and
+// arrays of them for slice fields, or objects of them for map fields) are
+// decoded like argument values, so choices are also checked. Other values are
+// unmarshalled into the field.
+func (p Parser) setConfig(target reflect.Value, seen map[parserKey]bool, field Field, raw json.RawMessage) error {
+	if text, ok := configText(raw); ok && !field.IsMap() {
+		return p.set(target, seen, field, text)
+	}
+
+	var texts []string
+
+	if field.IsSlice() {
+		var items []json.RawMessage
+
+		if json.Unmarshal(raw, &items) == nil {
+			for _, item := range items {
+				text, ok := configText(item)
+
+				if !ok {
+					texts = nil
+					break
+				}
+
+				texts = append(texts, text)
+			}
+		}
+	} else if field.IsMap() {
+		var entries map[string]json.RawMessage
+
+		if json.Unmarshal(raw, &entries) == nil {
+			for _, key := range slices.Sorted(maps.Keys(entries)) {
+				text, ok := configText(entries[key])
+
+				if !ok {
+					texts = nil
+					break
+				}
+
+				texts = append(texts, key+"="+text)
+			}
+		}
+	}
+
+	if texts != nil {
+		p.markSeen(target, seen, field)
+
+		for _, text := range texts {
+			if err := p.set(target, seen, field, text); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if len(field.Choices()) > 0 {
+		return errors.New("expected a string, number, or boolean")
+	}
+
+	value := reflect.New(field.Type())
+
+	if err := json.Unmarshal(raw, value.Interface()); err != nil {
+		return err
+	}
+
+	p.markSeen(target, seen, field)
+	field.get(target).Set(value.Elem())
+	return nil
+}
+
+func (p Parser) parsePrompt(target reflect.Value, seen map[parserKey]bool) error {
+	if p.Prompter == nil {
+		return nil
+	}
+
+	for field := range FieldIterator(p.StructType) {
+		if field.Prompt() == "" || seen[parserKey{p.StructType, field.Name()}] {
+			continue
+		}
+
+		value, ok, err := p.Prompter.Prompt(field)
+
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			// Prompting is not possible, so skip all remaining prompts.
+			return nil
+		}
+
+		if value == "" {
+			continue
+		}
+
+		if err := p.set(target, seen, field, value); err != nil {
+			return fmt.Errorf("invalid value %q for %s: %w", value, field.label(), err)
+		}
+	}
+
+	return nil
+}
+
+// Check field group constraints of the struct type and the persistent structs.
+// Fields set by arguments (at any command level) or environment variables are
+// considered set, but default values are not.
+func (p Parser) parseGroups(seen map[parserKey]bool) error {
+	structTypes := []reflect.Type{p.StructType}
+
+	for _, persistent := range p.Persistent {
+		structTypes = append(structTypes, reflect.TypeOf(persistent).Elem())
+	}
+
+	for _, structType := range structTypes {
+		for _, group := range fieldGroups(structType) {
+			err := group.check(func(field Field) bool {
+				_, ok := seen[parserKey{structType, field.Name()}]
+				return ok
+			})
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (p Parser) set(target reflect.Value, seen map[parserKey]bool, field Field, value string) error {
+	var key any
+
+	if field.IsMap() {
+		rawKey, rawValue, ok := strings.Cut(value, "=")
+
+		if !ok {
+			return fmt.Errorf("expected key=value")
+		}
+
+		decodedKey, err := p.Decoder.Decode(rawKey, field.KeyType())
+
+		if err != nil {
+			return err
+		}
+
+		key = decodedKey
+		value = rawValue
+	}
+
+	if choices := field.Choices(); len(choices) > 0 && !slices.Contains(choices, value) {
+		return fmt.Errorf("must be one of %s", strings.Join(shorthand.Select(choices, func(_ int, choice string) string {
+			return strconv.Quote(choice)
+		}), ", "))
+	}
+
 	decoded, err := p.Decoder.Decode(value, field.DecodeType())
 
 	if err != nil {
 		return err
 	}
 
-	field.Set(target, decoded)
+	p.markSeen(target, seen, field)
+
+	if field.IsMap() {
+		field.SetEntry(target, key, decoded)
+	} else {
+		field.Set(target, decoded)
+	}
 
 	return nil
 }
 
-type parseFlagSetWriter struct{}
+// Mark the field as seen. The first time a field is seen, it is reset so that
+// default slice, map, and count values are replaced instead of appended to.
+func (p Parser) markSeen(target reflect.Value, seen map[parserKey]bool, field Field) {
+	key := parserKey{target.Type().Elem(), field.Name()}
+
+	if seen != nil && !seen[key] {
+		field.reset(target)
+		seen[key] = true
+	}
+}
+
+// Named flag field and the struct pointer it belongs to.
+type parserFlag struct {
+	field  Field
+	target reflect.Value
+	negate bool
+
+	// Deprecation warning message. Deprecated flags with a forward flag have
+	// the forward flag's field and target.
+	deprecated string
+}
+
+// Error returned by [Parser.Parse] when an argument is an undefined flag.
+type UnknownFlagError struct {
+	Flag string
+}
 
-func (w *parseFlagSetWriter) Write(b []byte) (int, error) {
-	return len(b), nil
+func (e *UnknownFlagError) Error() string {
+	return fmt.Sprintf("unknown flag %q", e.Flag)
 }
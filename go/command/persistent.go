@@ -0,0 +1,127 @@
+package command
+
+import (
+	"context"
+	"maps"
+	"reflect"
+	"slices"
+
+	"seahax.com/go/shorthand"
+)
+
+var persistentContextKey = shorthand.NewContextKey[[]any](nil)
+var persistentSeenContextKey = shorthand.NewContextKey[map[parserKey]bool](nil)
+
+// Declare a persistent option struct type. Persistent flags are parsed at the
+// command's level (before a subcommand name) and also by all descendant
+// commands. Use [ContextPersistent] to get the parsed values in an action.
+func Persistent[P any]() Modifier {
+	return Modify(func(command CommandMutable) {
+		command.AddPersistent(reflect.TypeFor[P]())
+	})
+}
+
+// Get the parsed persistent options of type P from an action context, or nil
+// if no command in the chain declares the persistent type.
+func ContextPersistent[P any](ctx context.Context) *P {
+	for _, value := range persistentContextKey.Value(ctx) {
+		if value, ok := value.(*P); ok {
+			return value
+		}
+	}
+
+	return nil
+}
+
+// Return the persistent option values inherited from the context, plus new
+// values (with only defaults and environment variables applied) for the
+// command's own persistent types. Also return the persistent fields which are
+// set, for group checks and so that repeated slice flags at descendant levels
+// are appended to instead of replacing parent level values.
+func persistentValues(ctx context.Context, command CommandImmutable) ([]any, map[parserKey]bool, error) {
+	// Inherited values are copied, so that parsing does not modify the values of
+	// other runs (eg. shell lines) which share the context.
+	values := persistentClone(persistentContextKey.Value(ctx))
+	seen := shorthand.Coalesce(maps.Clone(persistentSeenContextKey.Value(ctx)), map[parserKey]bool{})
+
+	for structType := range command.Persistent() {
+		if slices.ContainsFunc(values, func(value any) bool {
+			return reflect.TypeOf(value).Elem() == structType
+		}) {
+			// A type declared by more than one command is shared.
+			continue
+		}
+
+		parser := NewParser(structType, command.Decoder())
+		parser.Getter = command.Getter()
+		parser.EnvPrefix = command.EnvPrefix()
+		value, err := parser.parseDefaultsAndEnv(seen)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return values, seen, nil
+}
+
+// Return copies of persistent option values. Slice and map fields (including
+// fields of nested structs) are copied, so that parsing into the copies does
+// not modify the original values.
+func persistentClone(values []any) []any {
+	return shorthand.Select(values, func(_ int, value any) any {
+		clone := reflect.New(reflect.TypeOf(value).Elem())
+		clone.Elem().Set(reflect.ValueOf(value).Elem())
+		persistentCloneFields(clone.Elem())
+		return clone.Interface()
+	})
+}
+
+func persistentCloneFields(value reflect.Value) {
+	for i := range value.NumField() {
+		field := value.Field(i)
+
+		if !field.CanSet() {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.Struct:
+			persistentCloneFields(field)
+		case reflect.Slice:
+			if !field.IsNil() {
+				field.Set(reflect.AppendSlice(reflect.MakeSlice(field.Type(), 0, field.Len()), field))
+			}
+		case reflect.Map:
+			if !field.IsNil() {
+				clone := reflect.MakeMapWithSize(field.Type(), field.Len())
+
+				for iter := field.MapRange(); iter.Next(); {
+					clone.SetMapIndex(iter.Key(), iter.Value())
+				}
+
+				field.Set(clone)
+			}
+		}
+	}
+}
+
+// Return the persistent option struct types declared by the command and its
+// parents, root first.
+func persistentTypes(command CommandImmutable) []reflect.Type {
+	types := []reflect.Type{}
+
+	if parent := command.Parent(); parent != nil {
+		types = persistentTypes(parent)
+	}
+
+	for structType := range command.Persistent() {
+		if !slices.Contains(types, structType) {
+			types = append(types, structType)
+		}
+	}
+
+	return types
+}
@@ -0,0 +1,136 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// Run external plugin commands when no built-in subcommand matches. A plugin
+// is an executable on the PATH named "<fullname>-<subcommand>" (eg.
+// "tool-deploy-foo" for "tool deploy foo"), which is run with the remaining
+// arguments and the process stdio. Plugins are listed in help text.
+func Plugins() Modifier {
+	return Modify(func(command CommandMutable) {
+		command.SetPlugins(true)
+	})
+}
+
+// Error returned when a plugin exits with a non-zero status. The plugin is
+// expected to have reported its own error, so only the exit code is
+// propagated.
+type pluginExitError struct {
+	*exec.ExitError
+}
+
+// Get the plugin exit code. If the plugin was killed by a signal, the exit
+// code is 128 plus the signal number (like shells), or [ExitCodeActionFailure]
+// if the signal is not known.
+func (e *pluginExitError) ExitCode() int {
+	if code := e.ExitError.ExitCode(); code >= 0 {
+		return code
+	}
+
+	if status, ok := e.Sys().(interface {
+		Signaled() bool
+		Signal() syscall.Signal
+	}); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return ExitCodeActionFailure
+}
+
+type plugin struct {
+	name string
+	path string
+}
+
+// Return the plugins of the command found on the PATH, sorted by name. If a
+// plugin is found in more than one PATH directory, the first is used.
+func pluginList(command CommandImmutable) []plugin {
+	prefix := pluginPrefix(command)
+	plugins := []plugin{}
+
+	for _, dir := range pluginPath(command) {
+		entries, err := os.ReadDir(dir)
+
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), prefix)
+
+			if !ok || name == "" || slices.ContainsFunc(plugins, func(p plugin) bool { return p.name == name }) {
+				continue
+			}
+
+			path := filepath.Join(dir, entry.Name())
+
+			if pluginIsExecutable(path) {
+				plugins = append(plugins, plugin{name: name, path: path})
+			}
+		}
+	}
+
+	slices.SortFunc(plugins, func(a, b plugin) int {
+		return strings.Compare(a.name, b.name)
+	})
+
+	return plugins
+}
+
+// Find the plugin executable for the subcommand name on the PATH.
+func pluginLookup(command CommandImmutable, name string) (string, bool) {
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		return "", false
+	}
+
+	for _, dir := range pluginPath(command) {
+		path := filepath.Join(dir, pluginPrefix(command)+name)
+
+		if pluginIsExecutable(path) {
+			return path, true
+		}
+	}
+
+	return "", false
+}
+
+// Run the plugin executable with the process stdio, and return a
+// [pluginExitError] if it exits with a non-zero status.
+func pluginRun(ctx context.Context, path string, args []string) error {
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+
+	if errors.As(err, &exitErr) {
+		return &pluginExitError{exitErr}
+	}
+
+	return err
+}
+
+func pluginPrefix(command CommandImmutable) string {
+	return strings.ReplaceAll(command.Fullname(), " ", "-") + "-"
+}
+
+func pluginPath(command CommandImmutable) []string {
+	path, _ := command.Getter().Get("PATH")
+	return filepath.SplitList(path)
+}
+
+func pluginIsExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode()&0o111 != 0
+}
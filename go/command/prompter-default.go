@@ -0,0 +1,111 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Default prompter which asks questions on the command output and reads
+// answers from stdin, if stdin is a terminal. Secret fields are read without
+// echo, and fields with choices show a numbered menu. An answer which is one
+// of the choices selects that choice, even if it is also a menu number.
+// Otherwise, a menu number selects the numbered choice.
+var PrompterDefault Prompter = &prompterDefault{input: &prompterInput{}}
+
+type prompterDefault struct {
+	input  *prompterInput
+	output io.Writer
+}
+
+// Stdin reader shared by all prompts, so that input buffered by one prompt is
+// not lost by the next prompt.
+type prompterInput struct {
+	file   *os.File
+	reader *bufio.Reader
+}
+
+// Get a copy of the prompter which writes to the output.
+func (p *prompterDefault) withOutput(output io.Writer) Prompter {
+	return &prompterDefault{input: p.input, output: output}
+}
+
+func (p *prompterDefault) Prompt(field Field) (string, bool, error) {
+	fd := int(os.Stdin.Fd())
+
+	if !term.IsTerminal(fd) {
+		return "", false, nil
+	}
+
+	output := p.output
+
+	if output == nil {
+		output = os.Stderr
+	}
+
+	question := field.Prompt()
+	choices := field.Choices()
+
+	if len(choices) > 0 {
+		fmt.Fprintf(output, "%s\n", question)
+
+		for i, choice := range choices {
+			fmt.Fprintf(output, "  %d) %s\n", i+1, choice)
+		}
+
+		question = fmt.Sprintf("Choose [1-%d]", len(choices))
+	}
+
+	if value := field.Default(); value != "" && !field.IsSecret() {
+		question += fmt.Sprintf(" (default: %s)", value)
+	}
+
+	fmt.Fprintf(output, "%s: ", question)
+
+	var answer string
+	var err error
+
+	if field.IsSecret() {
+		var bytes []byte
+		bytes, err = term.ReadPassword(fd)
+		answer = string(bytes)
+		fmt.Fprintln(output)
+	} else {
+		answer, err = p.input.get().ReadString('\n')
+	}
+
+	if err != nil {
+		return "", false, err
+	}
+
+	return prompterChoice(strings.TrimRight(answer, "\r\n"), choices), true, nil
+}
+
+func (i *prompterInput) get() *bufio.Reader {
+	if i.file != os.Stdin {
+		i.file = os.Stdin
+		i.reader = bufio.NewReader(os.Stdin)
+	}
+
+	return i.reader
+}
+
+// Get the choice selected by a menu answer. Choice values are matched before
+// menu numbers, so that numeric choices can be answered with their values.
+func prompterChoice(answer string, choices []string) string {
+	if slices.Contains(choices, answer) {
+		return answer
+	}
+
+	if index, err := strconv.Atoi(answer); err == nil && index >= 1 && index <= len(choices) {
+		return choices[index-1]
+	}
+
+	return answer
+}
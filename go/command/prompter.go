@@ -0,0 +1,34 @@
+package command
+
+import "io"
+
+// Ask for the value of a field that was not set by arguments or environment
+// variables.
+type Prompter interface {
+	// Ask for the value of a field. Return ok false if prompting is not
+	// possible (eg. stdin is not a terminal). An empty value keeps the current
+	// (default) value.
+	Prompt(field Field) (value string, ok bool, err error)
+}
+
+// Ask for the value of a field that was not set by arguments or environment
+// variables.
+type Prompt func(field Field) (value string, ok bool, err error)
+
+func (p Prompt) Prompt(field Field) (value string, ok bool, err error) {
+	return p(field)
+}
+
+// Prompter which can write to the output of the running command (eg. the
+// [PrompterDefault]).
+type prompterOutput interface {
+	withOutput(output io.Writer) Prompter
+}
+
+// Ask for missing values of fields tagged with the "prompt" tag, using the
+// [PrompterDefault] when stdin is a terminal.
+func Interactive() Modifier {
+	return Modify(func(command CommandMutable) {
+		command.SetPrompter(PrompterDefault)
+	})
+}
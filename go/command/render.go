@@ -0,0 +1,283 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"go.yaml.in/yaml/v3"
+	"seahax.com/go/shorthand"
+)
+
+// Output formats supported by [Renderer].
+const (
+	RenderTable    string = "table"
+	RenderJSON     string = "json"
+	RenderNDJSON   string = "ndjson"
+	RenderYAML     string = "yaml"
+	RenderTemplate string = "template"
+)
+
+const tagColumn string = "column"
+
+// Output format options. Embed in a command options struct to add the
+// "--output" and "--template" flags used by [NewRender] commands.
+type RenderOptions struct {
+	Output   string `flag:"-o, --output <format>" help:"Output format" choices:"table|json|ndjson|yaml|template" default:"table"`
+	Template string `flag:"--template <text>" help:"Go template for the template output format"`
+}
+
+func (o *RenderOptions) renderOptions() *RenderOptions {
+	return o
+}
+
+type renderOptionsProvider interface {
+	renderOptions() *RenderOptions
+}
+
+// Renderer writes records of type R in one of the supported output formats.
+// Table, JSON, and YAML output is written when the renderer is closed.
+// NDJSON and template output is written as each record is rendered.
+type Renderer[R any] struct {
+	writer   io.Writer
+	format   string
+	template *template.Template
+	table    *tabwriter.Writer
+	columns  []renderColumn
+	records  []R
+}
+
+type renderColumn struct {
+	title string
+	index []int
+}
+
+// Create a new [Renderer] which writes records to the writer in the format
+// selected by the options.
+func NewRenderer[R any](writer io.Writer, options RenderOptions) (*Renderer[R], error) {
+	renderer := &Renderer[R]{writer: writer, format: options.Output}
+
+	switch options.Output {
+	case "", RenderTable:
+		renderer.format = RenderTable
+		renderer.columns = renderColumns(reflect.TypeFor[R]())
+	case RenderJSON, RenderNDJSON, RenderYAML:
+	case RenderTemplate:
+		if options.Template == "" {
+			return nil, errors.New("template output format requires a template")
+		}
+
+		tmpl, err := template.New("output").Parse(options.Template)
+
+		if err != nil {
+			return nil, err
+		}
+
+		renderer.template = tmpl
+	default:
+		return nil, fmt.Errorf("unknown output format: %s", options.Output)
+	}
+
+	return renderer, nil
+}
+
+// Render a single record.
+func (r *Renderer[R]) Render(record R) error {
+	switch r.format {
+	case RenderTable:
+		if r.table == nil {
+			r.table = tabwriter.NewWriter(r.writer, 0, 0, 2, ' ', 0)
+			r.tableRow(shorthand.Select(r.columns, func(_ int, column renderColumn) string {
+				return column.title
+			}))
+		}
+
+		r.tableRow(renderCells(r.columns, reflect.ValueOf(record)))
+		return nil
+	case RenderNDJSON:
+		return json.NewEncoder(r.writer).Encode(record)
+	case RenderTemplate:
+		var builder strings.Builder
+
+		if err := r.template.Execute(&builder, record); err != nil {
+			return err
+		}
+
+		_, err := fmt.Fprintln(r.writer, strings.TrimSuffix(builder.String(), "\n"))
+		return err
+	default:
+		r.records = append(r.records, record)
+		return nil
+	}
+}
+
+// Render each record of a slice.
+func (r *Renderer[R]) RenderAll(records []R) error {
+	for _, record := range records {
+		if err := r.Render(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write any buffered output. A table with no records is not written, and
+// JSON and YAML output with no records is an empty list.
+func (r *Renderer[R]) Close() error {
+	switch r.format {
+	case RenderTable:
+		if r.table == nil {
+			return nil
+		}
+
+		return r.table.Flush()
+	case RenderJSON:
+		encoder := json.NewEncoder(r.writer)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r.nonNilRecords())
+	case RenderYAML:
+		encoder := yaml.NewEncoder(r.writer)
+		encoder.SetIndent(2)
+
+		if err := encoder.Encode(r.nonNilRecords()); err != nil {
+			return err
+		}
+
+		return encoder.Close()
+	default:
+		return nil
+	}
+}
+
+func (r *Renderer[R]) nonNilRecords() []R {
+	if r.records == nil {
+		return []R{}
+	}
+
+	return r.records
+}
+
+func (r *Renderer[R]) tableRow(cells []string) {
+	fmt.Fprintln(r.table, strings.Join(cells, "\t"))
+}
+
+// Create a new [Command] with an action that renders records of type R. The
+// options struct T must embed [RenderOptions]. The action calls the render
+// function for each record (in order), and the records are written to the
+// command output in the format selected by the "--output" flag. If the action
+// returns an error, the records rendered before the error are still written.
+// Like [NewContext], the context is cancelled when an interrupt or termination
+// signal is received.
+func NewRender[T any, R any](name string, summary string, action func(ctx context.Context, opts *T, render func(record R) error) error, modifiers ...Modifier) Command[T] {
+	if !reflect.PointerTo(reflect.TypeFor[T]()).Implements(reflect.TypeFor[renderOptionsProvider]()) {
+		panic(fmt.Sprintf("render command options type %s does not embed RenderOptions", reflect.TypeFor[T]()))
+	}
+
+	return newCommand(name, summary, func(ctx context.Context, opts *T) error {
+		renderer, err := NewRenderer[R](ContextCommand(ctx).Output(), *any(opts).(renderOptionsProvider).renderOptions())
+
+		if err != nil {
+			return NewError(err, true)
+		}
+
+		err = action(ctx, opts, renderer.Render)
+
+		if closeErr := renderer.Close(); err == nil {
+			err = closeErr
+		}
+
+		return err
+	}, true, modifiers)
+}
+
+// Get the table columns of a record type. Struct fields tagged with the
+// "column" tag are columns, titled by the tag value. If no fields are tagged,
+// all exported fields are columns, titled by their upper-cased names. Records
+// which are not structs have a single "VALUE" column. A "column" tag value of
+// "-" excludes the field.
+func renderColumns(recordType reflect.Type) []renderColumn {
+	for recordType.Kind() == reflect.Pointer {
+		recordType = recordType.Elem()
+	}
+
+	if recordType.Kind() != reflect.Struct {
+		return []renderColumn{{title: "VALUE"}}
+	}
+
+	tagged := []renderColumn{}
+	untagged := []renderColumn{}
+
+	for _, structField := range reflect.VisibleFields(recordType) {
+		if !structField.IsExported() || structField.Anonymous {
+			continue
+		}
+
+		title, ok := structField.Tag.Lookup(tagColumn)
+
+		if title == "-" {
+			continue
+		}
+
+		if ok {
+			tagged = append(tagged, renderColumn{title: title, index: structField.Index})
+		} else {
+			untagged = append(untagged, renderColumn{title: strings.ToUpper(structField.Name), index: structField.Index})
+		}
+	}
+
+	if len(tagged) > 0 {
+		return tagged
+	}
+
+	return untagged
+}
+
+func renderCells(columns []renderColumn, value reflect.Value) []string {
+	for value.IsValid() && (value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) {
+		if value.IsNil() {
+			return make([]string, len(columns))
+		}
+
+		value = value.Elem()
+	}
+
+	if !value.IsValid() {
+		return make([]string, len(columns))
+	}
+
+	cells := []string{}
+
+	for _, column := range columns {
+		if column.index == nil {
+			cells = append(cells, renderCell(value))
+			continue
+		}
+
+		field, err := value.FieldByIndexErr(column.index)
+
+		if err != nil {
+			cells = append(cells, "")
+			continue
+		}
+
+		cells = append(cells, renderCell(field))
+	}
+
+	return cells
+}
+
+func renderCell(value reflect.Value) string {
+	if !value.IsValid() || ((value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface) && value.IsNil()) {
+		return ""
+	}
+
+	cell := fmt.Sprint(value.Interface())
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(cell)
+}
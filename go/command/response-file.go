@@ -0,0 +1,131 @@
+package command
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const responseFileMaxDepth = 10
+
+// Expand "@path" arguments to the arguments read from the response file at
+// the path, before parsing. Response file arguments are separated by
+// whitespace, may be single or double quoted, and lines starting with "#" are
+// comments. Use "@@" to pass a literal "@" prefixed argument (eg. a file
+// source value).
+func ResponseFiles() Modifier {
+	return Modify(func(command CommandMutable) {
+		command.SetResponseFiles(true)
+	})
+}
+
+// Expand response file arguments (see [ResponseFiles]). Arguments after a "--"
+// terminator are not expanded.
+func expandResponseFiles(args []string, depth int) ([]string, error) {
+	expanded := []string{}
+
+	for i, arg := range args {
+		if arg == "--" {
+			expanded = append(expanded, args[i:]...)
+			break
+		}
+
+		if strings.HasPrefix(arg, "@@") {
+			expanded = append(expanded, arg[1:])
+			continue
+		}
+
+		if len(arg) < 2 || arg[0] != '@' {
+			expanded = append(expanded, arg)
+			continue
+		}
+
+		if depth >= responseFileMaxDepth {
+			return nil, fmt.Errorf("response file %q is nested too deeply", arg[1:])
+		}
+
+		content, err := os.ReadFile(arg[1:])
+
+		if err != nil {
+			return nil, fmt.Errorf("failed reading response file: %w", err)
+		}
+
+		fileArgs, err := splitArgs(string(content))
+
+		if err != nil {
+			return nil, fmt.Errorf("failed parsing response file %q: %w", arg[1:], err)
+		}
+
+		if fileArgs, err = expandResponseFiles(fileArgs, depth+1); err != nil {
+			return nil, err
+		}
+
+		expanded = append(expanded, fileArgs...)
+	}
+
+	return expanded, nil
+}
+
+// Split shell-like text (eg. response file content or a shell line) into
+// arguments.
+func splitArgs(content string) ([]string, error) {
+	args := []string{}
+	b := &strings.Builder{}
+	inArg := false
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			if inArg {
+				args = append(args, b.String())
+				b.Reset()
+				inArg = false
+			}
+		case c == '#' && !inArg:
+			for i < len(content) && content[i] != '\n' {
+				i++
+			}
+		case c == '\'':
+			end := strings.IndexByte(content[i+1:], '\'')
+
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated single quote")
+			}
+
+			b.WriteString(content[i+1 : i+1+end])
+			i += end + 1
+			inArg = true
+		case c == '"':
+			i++
+
+			for ; i < len(content) && content[i] != '"'; i++ {
+				if content[i] == '\\' && i+1 < len(content) && (content[i+1] == '"' || content[i+1] == '\\') {
+					i++
+				}
+
+				b.WriteByte(content[i])
+			}
+
+			if i >= len(content) {
+				return nil, fmt.Errorf("unterminated double quote")
+			}
+
+			inArg = true
+		case c == '\\' && i+1 < len(content):
+			i++
+			b.WriteByte(content[i])
+			inArg = true
+		default:
+			b.WriteByte(c)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, b.String())
+	}
+
+	return args, nil
+}
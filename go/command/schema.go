@@ -0,0 +1,161 @@
+package command
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"slices"
+
+	"seahax.com/go/shorthand"
+)
+
+// Machine-readable description of a command tree (see [NewSchema]).
+type Schema struct {
+	Name        string        `json:"name"`
+	Aliases     []string      `json:"aliases"`
+	Fullname    string        `json:"fullname"`
+	Summary     string        `json:"summary"`
+	Usage       []string      `json:"usage"`
+	Prologue    []string      `json:"prologue"`
+	Epilogue    []string      `json:"epilogue"`
+	Hidden      bool          `json:"hidden"`
+	Deprecated  string        `json:"deprecated"`
+	EnvPrefix   string        `json:"envPrefix"`
+	Fields      []SchemaField `json:"fields"`
+	Subcommands []Schema      `json:"subcommands"`
+}
+
+// Machine-readable description of a command struct field.
+type SchemaField struct {
+	Name         string   `json:"name"`
+	Flag         string   `json:"flag"`
+	FlagNames    []string `json:"flagNames"`
+	IsNamedFlag  bool     `json:"isNamedFlag"`
+	IsRequired   bool     `json:"isRequired"`
+	MinArgs      int      `json:"minArgs"`
+	MaxArgs      int      `json:"maxArgs"`
+	Help         string   `json:"help"`
+	Section      string   `json:"section"`
+	Type         string   `json:"type"`
+	IsSlice      bool     `json:"isSlice"`
+	IsMap        bool     `json:"isMap"`
+	IsCount      bool     `json:"isCount"`
+	IsNegatable  bool     `json:"isNegatable"`
+	IsPersistent bool     `json:"isPersistent"`
+	Env          string   `json:"env"`
+	Default      string   `json:"default"`
+	Choices      []string `json:"choices"`
+	Validate     string   `json:"validate"`
+	Hidden       bool     `json:"hidden"`
+	Deprecated   string   `json:"deprecated"`
+	Forward      string   `json:"forward"`
+}
+
+// Create a [Schema] for the command and all of its subcommands, including
+// hidden subcommands and fields. Fields of persistent option types declared by
+// the command are included and marked persistent.
+func NewSchema(command CommandImmutable) Schema {
+	names := slices.Collect(command.Names())
+	schema := Schema{
+		Name:        shorthand.FirstSeqValue(command.Names()),
+		Aliases:     schemaStrings(names[min(1, len(names)):]),
+		Fullname:    command.Fullname(),
+		Summary:     command.Summary(),
+		Usage:       shorthand.Select(helpUsage(command), schemaUsage),
+		Prologue:    schemaStrings(slices.Collect(command.Prologue())),
+		Epilogue:    schemaStrings(slices.Collect(command.Epilogue())),
+		Hidden:      command.IsHidden(),
+		Deprecated:  command.Deprecated(),
+		EnvPrefix:   command.EnvPrefix(),
+		Fields:      []SchemaField{},
+		Subcommands: []Schema{},
+	}
+
+	for field := range FieldIterator(command.Type()) {
+		schema.Fields = append(schema.Fields, newSchemaField(field, false))
+	}
+
+	for structType := range command.Persistent() {
+		for field := range FieldIterator(structType) {
+			schema.Fields = append(schema.Fields, newSchemaField(field, true))
+		}
+	}
+
+	for subcommand := range command.Subcommands() {
+		schema.Subcommands = append(schema.Subcommands, NewSchema(subcommand))
+	}
+
+	return schema
+}
+
+// Write the command tree [Schema] as indented JSON.
+func WriteSchema(w io.Writer, command CommandImmutable) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(NewSchema(command))
+}
+
+// Add a hidden root flag (eg. "--schema") which writes the command tree
+// [Schema] to stdout instead of running the command.
+func SchemaFlag(flag string) Modifier {
+	return Modify(func(command CommandMutable) {
+		command.SetFlagAction(flag, "", func(_ CommandImmutable, _ []string) error {
+			return WriteSchema(os.Stdout, command)
+		})
+	})
+}
+
+// Create a [SchemaField]. Positional arguments have the number of values
+// accepted by the parser (see [Field.ArgsRange]), where a maximum of zero is
+// unbounded. Named flags have zero minimum and maximum values.
+func newSchemaField(field Field, isPersistent bool) SchemaField {
+	var minArgs, maxArgs int
+
+	if !field.IsNamedFlag() {
+		minArgs, maxArgs = field.ArgsRange()
+
+		if !field.IsSlice() {
+			maxArgs = 1
+		}
+	}
+
+	return SchemaField{
+		Name:         field.Name(),
+		Flag:         field.Flag(),
+		FlagNames:    schemaStrings(slices.Collect(field.FlagNames())),
+		IsNamedFlag:  field.IsNamedFlag(),
+		IsRequired:   field.IsRequired(),
+		MinArgs:      minArgs,
+		MaxArgs:      maxArgs,
+		Help:         field.Help(),
+		Section:      field.Section(),
+		Type:         field.Type().String(),
+		IsSlice:      field.IsSlice(),
+		IsMap:        field.IsMap(),
+		IsCount:      field.IsCount(),
+		IsNegatable:  field.IsNegatable(),
+		IsPersistent: isPersistent,
+		Env:          field.Env(),
+		Default:      field.Default(),
+		Choices:      field.Choices(),
+		Validate:     field.Validate(),
+		Hidden:       field.IsHidden(),
+		Deprecated:   field.Deprecated(),
+		Forward:      field.Forward(),
+	}
+}
+
+// Return the usage line without the "Usage:" prefix.
+func schemaUsage(_ int, usage string) string {
+	return docsUsagePrefix.ReplaceAllString(usage, "")
+}
+
+// Return the values, or an empty (non-nil) slice so that JSON lists are never
+// null.
+func schemaStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+
+	return values
+}
@@ -0,0 +1,266 @@
+package command
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"golang.org/x/term"
+	"seahax.com/go/shorthand"
+)
+
+type shellOpts struct{}
+
+var shellContextKey = shorthand.NewContextKey[bool](nil)
+
+// Command which can be run with arguments (eg. a [Command] pointer).
+type Runner interface {
+	CommandImmutable
+	RunArgsContext(ctx context.Context, args []string) *Error
+}
+
+// Add a "shell" subcommand which runs the command tree as an interactive shell
+// (see [RunShell]). The shell runs the parent of the "shell" subcommand (as
+// resolved when it runs), so persistent options parsed before the "shell"
+// subcommand name apply to every shell line.
+func Shell() Modifier {
+	return Modify(func(command CommandMutable) {
+		command.AddSubcommand(newBuiltin("shell", "Start an interactive shell", func(ctx context.Context, _ *shellOpts) error {
+			if shellContextKey.Value(ctx) {
+				return errors.New("already in a shell")
+			}
+
+			return RunShell(ctx, ContextCommand(ctx).Parent().(Runner))
+		}))
+	})
+}
+
+// Run the command tree as an interactive shell. Lines are read from stdin,
+// split into arguments with shell-like quoting, and run as root command
+// arguments. Errors are reported to the command output, and the shell keeps
+// going until stdin ends, or the "exit" (or "quit") built-in is run. The
+// "help [command...]" built-in prints command help text. Subcommands take
+// precedence over built-ins with the same names. Shells cannot be nested, so
+// the "shell" subcommand fails when it is run in a shell.
+//
+// If stdin is a terminal, the shell has line editing, history, and tab
+// completion of subcommand and flag names.
+func RunShell(ctx context.Context, root Runner) error {
+	ctx = shellContextKey.ApplyValue(ctx, true)
+	fd := int(os.Stdin.Fd())
+
+	if !term.IsTerminal(fd) {
+		scanner := bufio.NewScanner(os.Stdin)
+
+		for scanner.Scan() {
+			if !shellRunLine(ctx, root, scanner.Text()) {
+				break
+			}
+		}
+
+		return scanner.Err()
+	}
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, root.Name()+"> ")
+	terminal.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		return shellComplete(terminal, root, line, pos, key)
+	}
+
+	for ctx.Err() == nil {
+		state, err := term.MakeRaw(fd)
+
+		if err != nil {
+			return err
+		}
+
+		line, err := terminal.ReadLine()
+
+		// The terminal is only raw while reading, so that command output is not
+		// affected.
+		term.Restore(fd, state)
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !shellRunLine(ctx, root, line) {
+			return nil
+		}
+	}
+
+	return ctx.Err()
+}
+
+// Run a shell line. Returns false if the shell should exit.
+func shellRunLine(ctx context.Context, root Runner, line string) bool {
+	args, err := splitArgs(line)
+
+	if err != nil {
+		fmt.Fprintln(root.Output(), err.Error())
+		return true
+	}
+
+	if len(args) == 0 {
+		return true
+	}
+
+	_, isSubcommand := lookupSubcommand(root, args[0])
+
+	switch {
+	case isSubcommand:
+		// Subcommands take precedence over built-ins with the same names.
+	case args[0] == "exit", args[0] == "quit":
+		return false
+	case args[0] == "help":
+		var command CommandImmutable = root
+
+		for _, name := range args[1:] {
+			subcommand, ok := lookupSubcommand(command, name)
+
+			if !ok {
+				fmt.Fprintf(command.Output(), "unknown command %q\n", name)
+				return true
+			}
+
+			command = subcommand
+		}
+
+		command.PrintHelp()
+		return true
+	}
+
+	if err := root.RunArgsContext(ctx, args); err != nil {
+		output := err.Command().Output()
+
+		if errors.Is(err, flag.ErrHelp) {
+			err.Command().PrintHelp()
+			return true
+		}
+
+		fmt.Fprintln(output, err.Error())
+
+		for line := range strings.Lines(err.Hint()) {
+			fmt.Fprintf(output, "hint: %s\n", strings.TrimRight(line, "\n"))
+		}
+	}
+
+	return true
+}
+
+// Complete the word before the cursor when the tab key is pressed. If there
+// are multiple candidates, the common prefix is completed, or the candidates
+// are listed if there is no common prefix to add.
+func shellComplete(terminal *term.Terminal, root CommandImmutable, line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	words, err := splitArgs(line[:pos])
+
+	if err != nil {
+		return "", 0, false
+	}
+
+	prefix := ""
+
+	if len(words) > 0 && !strings.HasSuffix(line[:pos], " ") {
+		prefix = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	candidates := slices.DeleteFunc(shellCandidates(root, words), func(candidate string) bool {
+		return !strings.HasPrefix(candidate, prefix)
+	})
+
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	if len(candidates) == 1 {
+		completed := line[:pos-len(prefix)] + candidates[0] + " "
+		return completed + line[pos:], len(completed), true
+	}
+
+	common := candidates[0]
+
+	for _, candidate := range candidates[1:] {
+		for !strings.HasPrefix(candidate, common) {
+			common = common[:len(common)-1]
+		}
+	}
+
+	if len(common) > len(prefix) {
+		completed := line[:pos-len(prefix)] + common
+		return completed + line[pos:], len(completed), true
+	}
+
+	fmt.Fprintln(terminal, strings.Join(candidates, "  "))
+
+	return line, pos, true
+}
+
+// Return the completion candidates following the words. Candidates are
+// subcommand and flag names, and the dynamic completions of field values.
+func shellCandidates(root CommandImmutable, words []string) []string {
+	command := root
+
+	if len(words) > 0 && words[0] == "help" {
+		for _, word := range words[1:] {
+			if subcommand, ok := lookupSubcommand(command, word); ok {
+				command = subcommand
+			}
+		}
+
+		return shellSubcommandNames(command)
+	}
+
+	candidates := []string{}
+
+	if len(words) == 0 {
+		candidates = append(candidates, "help", "exit")
+	}
+
+	for _, word := range words {
+		subcommand, ok := lookupSubcommand(command, word)
+
+		if !ok {
+			break
+		}
+
+		command = subcommand
+	}
+
+	candidates = append(candidates, shellSubcommandNames(command)...)
+	options, _ := helpFields(command)
+
+	for _, field := range append(options, helpGlobalFields(command)...) {
+		for name := range field.FlagNames() {
+			candidates = append(candidates, formatFlagName(name))
+		}
+	}
+
+	return append(candidates, completeDynamic(root, append(slices.Clone(words), ""))...)
+}
+
+func shellSubcommandNames(command CommandImmutable) []string {
+	names := []string{}
+
+	for _, subcommand := range helpSubcommands(command) {
+		names = append(names, slices.Collect(subcommand.Names())...)
+	}
+
+	return names
+}
@@ -0,0 +1,146 @@
+package command
+
+import (
+	"cmp"
+	"fmt"
+	"iter"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"seahax.com/go/shorthand"
+)
+
+// Return the candidates which are similar to the input, ordered by
+// similarity. Candidates are similar if they start with the input, or if
+// their edit distance from the input is small relative to the input length.
+func suggest(input string, candidates iter.Seq[string]) []string {
+	type suggestion struct {
+		value    string
+		distance int
+	}
+
+	suggestions := []suggestion{}
+	maxDistance := len(input)/3 + 1
+
+	for candidate := range candidates {
+		if candidate == input || slices.ContainsFunc(suggestions, func(s suggestion) bool {
+			return s.value == candidate
+		}) {
+			continue
+		}
+
+		distance := suggestDistance(input, candidate)
+
+		if (distance <= maxDistance && distance < len(input)) || (len(input) > 1 && strings.HasPrefix(candidate, input)) {
+			suggestions = append(suggestions, suggestion{candidate, distance})
+		}
+	}
+
+	slices.SortStableFunc(suggestions, func(a, b suggestion) int {
+		return cmp.Or(cmp.Compare(a.distance, b.distance), cmp.Compare(a.value, b.value))
+	})
+
+	result := []string{}
+
+	for _, s := range suggestions {
+		result = append(result, s.value)
+	}
+
+	return result
+}
+
+// Optimal string alignment distance (Levenshtein distance plus adjacent
+// transpositions).
+func suggestDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+
+	return d[len(ra)][len(rb)]
+}
+
+// Format suggestions as a "did you mean" phrase.
+func suggestFormat(suggestions []string) string {
+	quoted := []string{}
+
+	for _, s := range suggestions {
+		quoted = append(quoted, fmt.Sprintf("%q", s))
+	}
+
+	switch len(quoted) {
+	case 0:
+		return ""
+	case 1:
+		return fmt.Sprintf("did you mean %s?", quoted[0])
+	case 2:
+		return fmt.Sprintf("did you mean %s or %s?", quoted[0], quoted[1])
+	default:
+		return fmt.Sprintf("did you mean %s, or %s?", strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
+	}
+}
+
+// Suggest named flags of the struct types which are similar to the unknown
+// flag argument.
+func suggestFlags(flagArg string, structTypes ...reflect.Type) []string {
+	candidates := map[string]string{}
+
+	for _, structType := range structTypes {
+		for field := range FieldIterator(structType) {
+			if field.IsHidden() || field.Deprecated() != "" {
+				continue
+			}
+
+			for name := range field.FlagNames() {
+				candidates[name] = formatFlagName(name)
+
+				if field.IsNegatable() && len(name) > 1 {
+					candidates["no-"+name] = formatFlagName("no-" + name)
+				}
+			}
+		}
+	}
+
+	return shorthand.Select(suggest(strings.TrimLeft(flagArg, "-"), maps.Keys(candidates)), func(_ int, name string) string {
+		return candidates[name]
+	})
+}
+
+// Suggest subcommand names (including aliases) which are similar to the
+// unknown subcommand name.
+func suggestSubcommands(name string, command CommandImmutable) []string {
+	return suggest(name, func(yield func(string) bool) {
+		for _, subcommand := range helpSubcommands(command) {
+			for alias := range subcommand.Names() {
+				if !yield(alias) {
+					return
+				}
+			}
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime/debug"
+	"strings"
+
+	"seahax.com/go/shorthand"
+)
+
+// Build information overrides, which take precedence over the values read
+// from [debug.ReadBuildInfo]. Set them with linker flags, for example:
+//
+//	go build -ldflags "-X seahax.com/go/command.BuildVersion=v1.2.3"
+var (
+	BuildVersion  string
+	BuildRevision string
+	BuildDirty    string
+	BuildTime     string
+)
+
+// Version information about the running binary.
+type VersionInfo struct {
+	Module    string `json:"module"`
+	Version   string `json:"version"`
+	Revision  string `json:"revision"`
+	Dirty     bool   `json:"dirty"`
+	Time      string `json:"time"`
+	GoVersion string `json:"goVersion"`
+}
+
+type versionOpts struct {
+	JSON bool `flag:"--json" help:"Print version information as JSON"`
+}
+
+// Add a "--version" flag (which is also accepted by all subcommands) and a
+// "version" subcommand which print the [VersionInfo] to the command output.
+// Both accept a "--json" flag for machine-readable output.
+func Version() Modifier {
+	return Modify(func(command CommandMutable) {
+		name := shorthand.FirstSeqValue(command.Names())
+
+		command.SetFlagAction("--version", "Print version information", func(running CommandImmutable, args []string) error {
+			opts, err := NewParser(reflect.TypeFor[versionOpts](), running.Decoder()).Parse(args)
+
+			if err != nil {
+				return NewError(err, true)
+			}
+
+			return WriteVersion(running.Output(), name, opts.(*versionOpts).JSON)
+		})
+		command.AddSubcommand(newBuiltin("version", "Print version information", func(ctx context.Context, opts *versionOpts) error {
+			return WriteVersion(ContextCommand(ctx).Output(), name, opts.JSON)
+		}))
+	})
+}
+
+// Read the version information from the build info and the Build* variable
+// overrides.
+func ReadVersionInfo() VersionInfo {
+	info := VersionInfo{}
+
+	if buildInfo, ok := debug.ReadBuildInfo(); ok {
+		info.Module = buildInfo.Main.Path
+		info.Version = buildInfo.Main.Version
+		info.GoVersion = buildInfo.GoVersion
+
+		for _, setting := range buildInfo.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Revision = setting.Value
+			case "vcs.modified":
+				info.Dirty = setting.Value == "true"
+			case "vcs.time":
+				info.Time = setting.Value
+			}
+		}
+	}
+
+	if BuildVersion != "" {
+		info.Version = BuildVersion
+	}
+
+	if BuildRevision != "" {
+		info.Revision = BuildRevision
+	}
+
+	if BuildDirty != "" {
+		info.Dirty = BuildDirty == "true"
+	}
+
+	if BuildTime != "" {
+		info.Time = BuildTime
+	}
+
+	if info.Version == "" {
+		info.Version = "(devel)"
+	}
+
+	return info
+}
+
+// Write the version information as text (or JSON) for the named command.
+func WriteVersion(w io.Writer, name string, asJSON bool) error {
+	info := ReadVersionInfo()
+
+	if asJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(info)
+	}
+
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "%s %s\n", name, info.Version)
+
+	if info.Module != "" {
+		fmt.Fprintf(b, "Module: %s\n", info.Module)
+	}
+
+	if info.Revision != "" {
+		if info.Dirty {
+			fmt.Fprintf(b, "Revision: %s (dirty)\n", info.Revision)
+		} else {
+			fmt.Fprintf(b, "Revision: %s\n", info.Revision)
+		}
+	}
+
+	if info.Time != "" {
+		fmt.Fprintf(b, "Built: %s\n", info.Time)
+	}
+
+	if info.GoVersion != "" {
+		fmt.Fprintf(b, "Go: %s\n", info.GoVersion)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}